@@ -3,11 +3,12 @@ import SearchBar from './components/SearchBar'
 import Filters from './components/Filters'
 import CardGrid from './components/CardGrid'
 import mtguruLogo from './assets/mtguru-logo.png'
-import { Card, SearchResponse } from './types/card'
+import { Card, ErrorResponse, QueryRewrite, StreamEvent } from './types/card'
 import './App.css'
 
 interface FilterOptions {
   set_type: string
+  set: string
   colors: string
   rarity: string
   [key: string]: string
@@ -16,6 +17,7 @@ interface FilterOptions {
 function App() {
   const [filters, setFilters] = useState<FilterOptions>({
     set_type: '',
+    set: '',
     colors: '',
     rarity: '',
   })
@@ -25,6 +27,7 @@ function App() {
   const [searchAttempted, setSearchAttempted] = useState(false)
   const [renderError, setRenderError] = useState<string | null>(null)
   const [isFiltersExpanded, setIsFiltersExpanded] = useState(false)
+  const [queryRewrite, setQueryRewrite] = useState<QueryRewrite | null>(null)
 
   // Error boundary for rendering
   useEffect(() => {
@@ -37,7 +40,7 @@ function App() {
     return () => window.removeEventListener('error', handleError)
   }, [])
 
-  const handleSearch = async (query: string) => {
+  const handleSearch = async (query: string, noCorrection = false) => {
     if (!query.trim()) {
       setError('Please enter a search query')
       return
@@ -47,61 +50,70 @@ function App() {
     setIsLoading(true)
     setError(null)
     setCards([])
+    setQueryRewrite(null)
     setSearchAttempted(true)
     setRenderError(null)
 
     try {
-      console.log('Sending request to:', 'http://localhost:8888/api/search')
-      const response = await fetch('http://localhost:8888/api/search', {
+      console.log('Sending request to:', 'http://localhost:8888/api/search/stream')
+      const response = await fetch('http://localhost:8888/api/search/stream', {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
         },
         body: JSON.stringify({
           "query": query,
-          "filters": filters
+          "filters": filters,
+          "no_correction": noCorrection
         }),
       })
 
       console.log('Response status:', response.status)
       
-      if (!response.ok) {
+      if (!response.ok || !response.body) {
         const errorText = await response.text()
         console.error('Server error response:', errorText)
-        throw new Error(`Search failed with status: ${response.status}. ${errorText}`)
-      }
-
-      const rawData = await response.text()
-      console.log('Raw response:', rawData)
-
-      let data: SearchResponse
-      try {
-        data = JSON.parse(rawData)
-      } catch (e) {
-        console.error('Failed to parse JSON:', e)
-        throw new Error('Invalid JSON response from server')
+        let errorMessage = errorText
+        try {
+          const errorBody: ErrorResponse = JSON.parse(errorText)
+          errorMessage = errorBody.error?.message ?? errorText
+        } catch {
+          // Not a JSON error envelope, show the raw text
+        }
+        throw new Error(`Search failed with status: ${response.status}. ${errorMessage}`)
       }
 
-      console.log('Parsed response data:', data)
-      
-      // Check for different possible response formats
-      if (data.data?.Get?.Mtguru) {
-        const searchResults = data.data.Get.Mtguru
-        console.log('Number of cards found:', searchResults.length)
-        
-        if (searchResults.length === 0) {
-          console.log('No cards found for query:', query)
+      // Each NDJSON line is one event; cards are shown chunk by chunk
+      const reader = response.body.pipeThrough(new TextDecoderStream()).getReader()
+      let buffered = ''
+      let pending: Card[] = []
+      for (;;) {
+        const { value, done } = await reader.read()
+        if (done) break
+        buffered += value
+        const lines = buffered.split('\n')
+        buffered = lines.pop() ?? ''
+        for (const line of lines) {
+          if (!line.trim()) continue
+          const event: StreamEvent = JSON.parse(line)
+          switch (event.event) {
+            case 'query_parsed':
+              setQueryRewrite(event.data.query_rewrite ?? null)
+              break
+            case 'hit':
+              pending.push(event.data)
+              break
+            case 'chunk': {
+              const chunk = pending
+              pending = []
+              setCards((previous) => [...previous, ...chunk])
+              setIsLoading(false)
+              break
+            }
+            case 'error':
+              throw new Error(`Search failed. ${event.data.message}`)
+          }
         }
-        
-        setCards(searchResults)
-      } else if (data.matches?.data?.Get?.Mtguru) {
-        // Handle old format
-        const searchResults = data.matches.data.Get.Mtguru
-        console.log('Number of cards found (old format):', searchResults.length)
-        setCards(searchResults)
-      } else {
-        console.error('Unexpected response format:', data)
-        throw new Error('Unexpected response format from server')
       }
     } catch (error) {
       console.error('Error during search:', error)
@@ -134,7 +146,19 @@ function App() {
         return <div className="no-results">No cards found. Try a different search.</div>
       }
 
-      return <CardGrid cards={cards} />
+      return (
+        <>
+          {queryRewrite?.corrected && (
+            <div className="did-you-mean">
+              Showing results for <strong>{queryRewrite.corrected}</strong>.{' '}
+              <button type="button" className="link-button" onClick={() => handleSearch(queryRewrite.original, true)}>
+                Search instead for {queryRewrite.original}
+              </button>
+            </div>
+          )}
+          <CardGrid cards={cards} />
+        </>
+      )
     } catch (error) {
       console.error('Error in renderContent:', error)
       return <div className="error-message">An error occurred while rendering the content</div>
@@ -6,6 +6,13 @@ interface CardGridProps {
   cards: Card[];
 }
 
+// Double-faced cards may only have images on their faces
+// Served through the server's image cache rather than hot-linking Scryfall
+const cardImage = (card: Card): string | undefined =>
+  card.scryfall_id
+    ? `http://localhost:8888/api/images/${card.scryfall_id}/large`
+    : card.image_uris?.large ?? card.card_faces?.[0]?.image_uris?.large;
+
 const CardGrid: React.FC<CardGridProps> = ({ cards }) => {
   return (
     <div className="card-grid">
@@ -18,9 +25,9 @@ const CardGrid: React.FC<CardGridProps> = ({ cards }) => {
           className="card-link"
         >
           <div className="card-item">
-            {card.image_uris?.large ? (
+            {cardImage(card) ? (
               <img 
-                src={card.image_uris.large} 
+                src={cardImage(card)} 
                 alt={card.name}
                 className="card-image"
                 loading="lazy"
@@ -1,8 +1,10 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
+import { CardSet, SetListResponse } from '../types/card';
 import '../styles/Filters.css';
 
 interface FilterOptions {
   set_type: string;
+  set: string;
   colors: string;
   rarity: string;
   [key: string]: string;
@@ -15,9 +17,25 @@ interface FiltersProps {
 const Filters: React.FC<FiltersProps> = ({ onFilterChange }) => {
   const [filters, setFilters] = useState<FilterOptions>({
     set_type: '',
+    set: '',
     colors: '',
     rarity: '',
   });
+  const [setTypes, setSetTypes] = useState<string[]>([]);
+  const [sets, setSets] = useState<CardSet[]>([]);
+
+  useEffect(() => {
+    fetch('http://localhost:8888/api/sets')
+      .then((response) => (response.ok ? response.json() : Promise.reject(response.status)))
+      .then((body: SetListResponse) => {
+        setSetTypes(body.set_types);
+        setSets(body.sets);
+      })
+      .catch((error) => console.error('Could not load sets:', error));
+  }, []);
+
+  const formatSetType = (setType: string) =>
+    setType.charAt(0).toUpperCase() + setType.slice(1).replace(/_/g, ' ');
 
   const handleFilterChange = (key: string, value: string) => {
     const newFilters = { ...filters, [key]: value };
@@ -29,19 +47,34 @@ const Filters: React.FC<FiltersProps> = ({ onFilterChange }) => {
     <div className="filters-container">
       <div className="filters-content">
         <div className="filter-group">
-          <label>Type</label>
+          <label>Set type</label>
           <select
             value={filters.set_type}
             onChange={(e) => handleFilterChange('set_type', e.target.value)}
           >
             <option value="">Any</option>
-            <option value="creature">Creature</option>
-            <option value="instant">Instant</option>
-            <option value="sorcery">Sorcery</option>
-            <option value="enchantment">Enchantment</option>
-            <option value="artifact">Artifact</option>
-            <option value="planeswalker">Planeswalker</option>
-            <option value="land">Land</option>
+            {setTypes.map((setType) => (
+              <option key={setType} value={setType}>
+                {formatSetType(setType)}
+              </option>
+            ))}
+          </select>
+        </div>
+
+        <div className="filter-group">
+          <label>Set</label>
+          <select
+            value={filters.set}
+            onChange={(e) => handleFilterChange('set', e.target.value)}
+          >
+            <option value="">Any</option>
+            {sets
+              .filter((set) => !filters.set_type || set.set_type === filters.set_type)
+              .map((set) => (
+                <option key={set.code} value={set.code}>
+                  {set.name} ({set.code.toUpperCase()})
+                </option>
+              ))}
           </select>
         </div>
 
@@ -57,7 +90,6 @@ const Filters: React.FC<FiltersProps> = ({ onFilterChange }) => {
             <option value="black">Black</option>
             <option value="red">Red</option>
             <option value="green">Green</option>
-            <option value="colorless">Colorless</option>
           </select>
         </div>
 
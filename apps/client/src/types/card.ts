@@ -2,20 +2,81 @@ export interface CardImageUris {
   large: string;
 }
 
+export interface CardFace {
+  name: string;
+  mana_cost: string;
+  type_line: string;
+  oracle_text?: string;
+  image_uris?: CardImageUris;
+}
+
 export interface CardAdditional {
   distance: number;
 }
 
+export interface SearchHighlight {
+  field: 'name' | 'type_line' | 'oracle_text';
+  term: string;
+  start: number;
+  end: number;
+  expanded?: boolean;
+}
+
+export interface SearchExplanation {
+  matched_terms: string[];
+  highlights: SearchHighlight[];
+  filters: string[];
+  distance?: number;
+  score?: number;
+  score_components?: Record<string, { original: number; normalized: number }>;
+}
+
 export interface Card {
   _additional: CardAdditional;
+  explanation?: SearchExplanation;
   image_uris?: CardImageUris;
+  layout?: string;
+  card_faces?: CardFace[];
   name: string;
   oracle_text: string;
   set_name: string;
   scryfall_uri: string;
+  scryfall_id?: string;
+}
+
+export interface ApiError {
+  code: string;
+  message: string;
+  field?: string;
+  request_id?: string;
+  details?: { field: string; message: string }[];
+}
+
+export interface ErrorResponse {
+  error: ApiError;
+}
+
+export interface QueryExpansion {
+  term: string;
+  expansion: string;
+  replaced: boolean;
+}
+
+export interface SpellingCorrection {
+  original: string;
+  corrected: string;
+}
+
+export interface QueryRewrite {
+  original: string;
+  searched: string;
+  corrected?: string;
+  corrections: SpellingCorrection[];
+  expansions: QueryExpansion[];
 }
 
 export interface SearchResponse {
+  query_rewrite?: QueryRewrite;
   data?: {
     Get: {
       Mtguru: Card[];
@@ -28,4 +89,31 @@ export interface SearchResponse {
       };
     };
   };
-} 
\ No newline at end of file
+} 
+export interface CardSet {
+  code: string;
+  name: string;
+  set_type: string;
+  released_at: string;
+  card_count: number;
+  parent_set_code?: string;
+  block?: string;
+  digital: boolean;
+  icon_svg_uri?: string;
+  scryfall_uri?: string;
+}
+
+export interface SetListResponse {
+  sets: CardSet[];
+  set_types: string[];
+}
+
+// One line of the /api/search/stream NDJSON response
+export type StreamEvent =
+  | { event: 'query_parsed'; data: { query_rewrite?: QueryRewrite } }
+  | { event: 'searching'; data: { mode: string; limit: number; candidates: number } }
+  | { event: 'retrieved'; data: { candidates: number } }
+  | { event: 'hit'; data: Card }
+  | { event: 'chunk'; data: { offset: number; count: number } }
+  | { event: 'done'; data: { total: number } }
+  | { event: 'error'; data: ApiError };
@@ -0,0 +1,153 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one search as recorded in the analytics log.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Query is normalized with NormalizeQuery so repeats group together
+	Query   string            `json:"query"`
+	Filters map[string]string `json:"filters,omitempty"`
+	Mode    string            `json:"mode"`
+	// Source is the endpoint that ran the search: search, stream or batch
+	Source  string `json:"source"`
+	Results int    `json:"results"`
+	// TopDistance is the smallest vector distance over the hits, absent for
+	// keyword search and when there were no results. TopScore is the highest
+	// keyword or hybrid score.
+	TopDistance *float64 `json:"top_distance,omitempty"`
+	TopScore    *float64 `json:"top_score,omitempty"`
+	LatencyMs   float64  `json:"latency_ms"`
+	// Error is the API error code when the search failed
+	Error string `json:"error,omitempty"`
+}
+
+// NormalizeQuery lower-cases a query and collapses its whitespace.
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+const (
+	currentFileName  = "searches.jsonl"
+	rotatedPrefix    = "searches-"
+	defaultMaxBytes  = 10 << 20
+	defaultMaxFiles  = 10
+	rotatedTimestamp = "20060102T150405.000000000"
+)
+
+// Logger appends events to <dir>/searches.jsonl. When the file would grow
+// past MaxBytes it is renamed to searches-<timestamp>.jsonl and only the
+// newest MaxFiles rotated files are kept.
+type Logger struct {
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewLogger opens the log in dir, creating the directory if needed. Zero
+// maxBytes or maxFiles use 10 MiB and 10 files.
+func NewLogger(dir string, maxBytes int64, maxFiles int) (*Logger, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{dir: dir, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	file, err := os.OpenFile(filepath.Join(l.dir, currentFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Record appends one event. It is safe for concurrent use.
+func (l *Logger) Record(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	rotated := filepath.Join(l.dir, rotatedPrefix+time.Now().UTC().Format(rotatedTimestamp)+".jsonl")
+	if err := os.Rename(filepath.Join(l.dir, currentFileName), rotated); err != nil {
+		return err
+	}
+
+	files, err := rotatedFiles(l.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > l.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return l.open()
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// rotatedFiles lists the rotated logs oldest first; the timestamp in the
+// name sorts chronologically.
+func rotatedFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, rotatedPrefix+"*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("listing analytics logs: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
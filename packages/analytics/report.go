@@ -0,0 +1,196 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ReadEvents reads every event in dir recorded at or after since, oldest
+// file first. Lines that do not parse, e.g. one cut short by a crash, are
+// skipped.
+func ReadEvents(dir string, since time.Time) ([]Event, error) {
+	files, err := rotatedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(dir, currentFileName))
+
+	var events []Event
+	for _, path := range files {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for scanner.Scan() {
+			var event Event
+			if json.Unmarshal(scanner.Bytes(), &event) != nil {
+				continue
+			}
+			if !event.Time.Before(since) {
+				events = append(events, event)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+	return events, nil
+}
+
+// QueryStat aggregates every recorded search for one normalized query.
+type QueryStat struct {
+	Query string `json:"query"`
+	Count int    `json:"count"`
+	// ZeroResults counts the searches for the query that returned nothing
+	ZeroResults int `json:"zero_results"`
+	// BestDistance is the lowest top-hit distance seen for the query
+	BestDistance   *float64  `json:"best_distance,omitempty"`
+	AvgTopDistance *float64  `json:"avg_top_distance,omitempty"`
+	LastSeen       time.Time `json:"last_seen"`
+}
+
+type Report struct {
+	Since        time.Time   `json:"since"`
+	Searches     int         `json:"searches"`
+	Failed       int         `json:"failed"`
+	AvgLatencyMs float64     `json:"avg_latency_ms"`
+	P95LatencyMs float64     `json:"p95_latency_ms"`
+	TopQueries   []QueryStat `json:"top_queries"`
+	// ZeroResults are the queries that returned nothing, most frequent first
+	ZeroResults []QueryStat `json:"zero_results"`
+	// PoorMatches are the queries whose best top-hit distance is above the
+	// threshold, worst first
+	PoorMatches []QueryStat `json:"poor_matches"`
+	// PoorDistance is the threshold PoorMatches was built with
+	PoorDistance float64 `json:"poor_distance"`
+}
+
+// BuildReport aggregates events into the top queries, the queries that
+// returned nothing, and the queries whose best match is further than
+// poorDistance. Each list is cut to limit entries.
+func BuildReport(events []Event, since time.Time, poorDistance float64, limit int) Report {
+	report := Report{
+		Since:        since,
+		PoorDistance: poorDistance,
+		TopQueries:   []QueryStat{},
+		ZeroResults:  []QueryStat{},
+		PoorMatches:  []QueryStat{},
+	}
+
+	stats := map[string]*QueryStat{}
+	distanceSums := map[string]float64{}
+	distanceCounts := map[string]int{}
+	var latencies []float64
+	for _, event := range events {
+		report.Searches++
+		latencies = append(latencies, event.LatencyMs)
+		if event.Error != "" {
+			report.Failed++
+			continue
+		}
+
+		stat, ok := stats[event.Query]
+		if !ok {
+			stat = &QueryStat{Query: event.Query}
+			stats[event.Query] = stat
+		}
+		stat.Count++
+		if event.Time.After(stat.LastSeen) {
+			stat.LastSeen = event.Time
+		}
+		if event.Results == 0 {
+			stat.ZeroResults++
+		}
+		if event.TopDistance != nil {
+			distance := *event.TopDistance
+			if stat.BestDistance == nil || distance < *stat.BestDistance {
+				stat.BestDistance = &distance
+			}
+			distanceSums[event.Query] += distance
+			distanceCounts[event.Query]++
+		}
+	}
+
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		total := 0.0
+		for _, latency := range latencies {
+			total += latency
+		}
+		report.AvgLatencyMs = total / float64(len(latencies))
+		report.P95LatencyMs = latencies[min(len(latencies)-1, len(latencies)*95/100)]
+	}
+
+	var all []QueryStat
+	for query, stat := range stats {
+		if count := distanceCounts[query]; count > 0 {
+			avg := distanceSums[query] / float64(count)
+			stat.AvgTopDistance = &avg
+		}
+		all = append(all, *stat)
+	}
+	// Ties break on the query so reports are stable between runs
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Count != all[j].Count {
+			return all[i].Count > all[j].Count
+		}
+		return all[i].Query < all[j].Query
+	})
+
+	for _, stat := range all {
+		if len(report.TopQueries) < limit {
+			report.TopQueries = append(report.TopQueries, stat)
+		}
+		if stat.ZeroResults > 0 && len(report.ZeroResults) < limit {
+			report.ZeroResults = append(report.ZeroResults, stat)
+		}
+		if stat.BestDistance != nil && *stat.BestDistance > poorDistance {
+			report.PoorMatches = append(report.PoorMatches, stat)
+		}
+	}
+	sort.SliceStable(report.PoorMatches, func(i, j int) bool {
+		return *report.PoorMatches[i].BestDistance > *report.PoorMatches[j].BestDistance
+	})
+	report.PoorMatches = report.PoorMatches[:min(limit, len(report.PoorMatches))]
+
+	return report
+}
+
+// WriteText prints a report as plain text tables for the CLI.
+func WriteText(w io.Writer, report Report) {
+	fmt.Fprintf(w, "Searches since %s: %d (%d failed)\n", report.Since.Format(time.RFC3339), report.Searches, report.Failed)
+	fmt.Fprintf(w, "Latency: avg %.0f ms, p95 %.0f ms\n", report.AvgLatencyMs, report.P95LatencyMs)
+
+	section := func(title string, stats []QueryStat) {
+		fmt.Fprintf(w, "\n%s\n", title)
+		if len(stats) == 0 {
+			fmt.Fprintln(w, "  (none)")
+			return
+		}
+		for _, stat := range stats {
+			best := "-"
+			if stat.BestDistance != nil {
+				best = fmt.Sprintf("%.3f", *stat.BestDistance)
+			}
+			fmt.Fprintf(w, "  %5d  zero:%-4d best:%-6s  %s\n", stat.Count, stat.ZeroResults, best, stat.Query)
+		}
+	}
+	section("Top queries", report.TopQueries)
+	section("Zero-result queries", report.ZeroResults)
+	section(fmt.Sprintf("Poor matches (best distance > %.2f)", report.PoorDistance), report.PoorMatches)
+}
@@ -0,0 +1,248 @@
+// Package cardquery parses the subset of Scryfall's search syntax the server
+// can turn into Weaviate filters, e.g. `t:creature c:rg cmc<=3 "llanowar"`.
+package cardquery
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Fields a Term can filter on.
+const (
+	FieldName      = "name"
+	FieldType      = "type"
+	FieldOracle    = "oracle"
+	FieldColors    = "colors"
+	FieldRarity    = "rarity"
+	FieldSet       = "set"
+	FieldSetType   = "set_type"
+	FieldManaValue = "cmc"
+	FieldArtist    = "artist"
+)
+
+// Comparison operators. ":" means "contains" for names, types, oracle text,
+// artists and colours and "equals" for everything else.
+const (
+	OpContains     = ":"
+	OpEqual        = "="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// keyFields maps every accepted key, including Scryfall's aliases, to its field.
+var keyFields = map[string]string{
+	"name": FieldName,
+	"t":    FieldType, "type": FieldType,
+	"o": FieldOracle, "oracle": FieldOracle,
+	"c": FieldColors, "color": FieldColors, "colors": FieldColors,
+	"r": FieldRarity, "rarity": FieldRarity,
+	"s": FieldSet, "e": FieldSet, "set": FieldSet, "edition": FieldSet,
+	"st": FieldSetType, "set_type": FieldSetType,
+	"cmc": FieldManaValue, "mv": FieldManaValue, "manavalue": FieldManaValue,
+	"a": FieldArtist, "artist": FieldArtist,
+}
+
+var rarities = map[string]string{
+	"c": "common", "common": "common",
+	"u": "uncommon", "uncommon": "uncommon",
+	"r": "rare", "rare": "rare",
+	"m": "mythic", "mythic": "mythic",
+	"s": "special", "special": "special",
+	"b": "bonus", "bonus": "bonus",
+}
+
+var colorNames = map[string]string{
+	"white": "W", "blue": "U", "black": "B", "red": "R", "green": "G",
+}
+
+// operators are tried longest first so "<=" is not read as "<".
+var operators = []string{OpLessEqual, OpGreaterEqual, OpContains, OpEqual, OpLess, OpGreater}
+
+// cutOperator splits a token at its first operator, so the value of
+// `o:x<=y` is "x<=y" rather than the key being "o:x".
+func cutOperator(token string) (key string, operator string, value string, found bool) {
+	for i := range token {
+		for _, op := range operators {
+			if strings.HasPrefix(token[i:], op) {
+				return token[:i], op, token[i+len(op):], true
+			}
+		}
+	}
+	return "", "", token, false
+}
+
+// Term is one condition of a query. Value is normalised for its field:
+// lower-cased words for text, upper-case colour letters, rarity names,
+// lower-case set codes and a decimal number for mana value.
+type Term struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// Number returns a mana value term's value.
+func (t Term) Number() float64 {
+	n, _ := strconv.ParseFloat(t.Value, 64)
+	return n
+}
+
+// Colors returns a colour term's colour letters, e.g. ["R", "G"].
+func (t Term) Colors() []string {
+	return strings.Split(t.Value, "")
+}
+
+// Parse splits a query into terms. Bare words and quoted phrases match card
+// names; every term must hold.
+func Parse(query string) ([]Term, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []Term{}
+	for _, token := range tokens {
+		term, err := parseToken(token)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// tokenize splits on whitespace outside double quotes, keeping the quotes.
+func tokenize(query string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", query)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+func parseToken(token string) (Term, error) {
+	if strings.HasPrefix(token, "-") {
+		return Term{}, fmt.Errorf("negated terms such as %q are not supported", token)
+	}
+
+	key, operator, value := "", "", token
+	if !strings.HasPrefix(token, `"`) {
+		if before, op, after, found := cutOperator(token); found && before != "" && !strings.ContainsAny(before, `"`) {
+			key, operator, value = strings.ToLower(before), op, after
+		}
+	}
+	value = strings.Trim(value, `"`)
+	if value == "" {
+		return Term{}, fmt.Errorf("%q has no value", token)
+	}
+
+	if key == "" {
+		return Term{Field: FieldName, Operator: OpContains, Value: strings.ToLower(value)}, nil
+	}
+	field, ok := keyFields[key]
+	if !ok {
+		return Term{}, fmt.Errorf("unknown search key %q", key)
+	}
+	return normalise(field, operator, value, token)
+}
+
+func normalise(field string, operator string, value string, token string) (Term, error) {
+	lower := strings.ToLower(value)
+	comparison := operator != OpContains && operator != OpEqual
+
+	switch field {
+	case FieldManaValue:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Term{}, fmt.Errorf("%q: mana value must be a number", token)
+		}
+		if operator == OpContains {
+			operator = OpEqual
+		}
+		return Term{Field: field, Operator: operator, Value: strconv.FormatFloat(n, 'f', -1, 64)}, nil
+	case FieldColors:
+		// Without a not operator Weaviate can't express "exactly these colours"
+		if operator != OpContains {
+			return Term{}, fmt.Errorf("%q: colours only support :, meaning at least these colours", token)
+		}
+		letters := colorNames[lower]
+		if letters == "" {
+			for _, r := range strings.ToUpper(value) {
+				if !strings.ContainsRune("WUBRG", r) {
+					return Term{}, fmt.Errorf("%q: colours are letters from wubrg or a colour name", token)
+				}
+				if !strings.ContainsRune(letters, r) {
+					letters += string(r)
+				}
+			}
+		}
+		return Term{Field: field, Operator: operator, Value: letters}, nil
+	}
+
+	if comparison {
+		return Term{}, fmt.Errorf("%q: %s only supports : and =", token, field)
+	}
+	switch field {
+	case FieldRarity:
+		rarity, ok := rarities[lower]
+		if !ok {
+			return Term{}, fmt.Errorf("%q: unknown rarity", token)
+		}
+		return Term{Field: field, Operator: OpEqual, Value: rarity}, nil
+	case FieldSet, FieldSetType:
+		return Term{Field: field, Operator: OpEqual, Value: lower}, nil
+	}
+	return Term{Field: field, Operator: OpContains, Value: lower}, nil
+}
+
+// Extract removes the terms on the given fields from a free-text query and
+// returns what is left, e.g. `flying artist:"rebecca guay"` with FieldArtist
+// gives "flying" and the artist term. Other words are left as they are.
+func Extract(query string, fields ...string) (string, []Term, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return query, nil, err
+	}
+
+	var rest []string
+	var terms []Term
+	for _, token := range tokens {
+		key, _, found := strings.Cut(token, OpContains)
+		if field := keyFields[strings.ToLower(key)]; found && !strings.HasPrefix(token, `"`) && slices.Contains(fields, field) {
+			term, err := parseToken(token)
+			if err != nil {
+				return query, nil, err
+			}
+			terms = append(terms, term)
+			continue
+		}
+		rest = append(rest, token)
+	}
+	if len(terms) == 0 {
+		return query, nil, nil
+	}
+	return strings.Join(rest, " "), terms, nil
+}
@@ -0,0 +1,87 @@
+package cardquery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Term
+	}{
+		{"cmc<=3", []Term{{Field: FieldManaValue, Operator: OpLessEqual, Value: "3"}}},
+		{"mv>=2.5", []Term{{Field: FieldManaValue, Operator: OpGreaterEqual, Value: "2.5"}}},
+		{"cmc<3", []Term{{Field: FieldManaValue, Operator: OpLess, Value: "3"}}},
+		{"cmc:3", []Term{{Field: FieldManaValue, Operator: OpEqual, Value: "3"}}},
+		{"cmc=03", []Term{{Field: FieldManaValue, Operator: OpEqual, Value: "3"}}},
+		// The first operator splits key from value
+		{"o:x<=y", []Term{{Field: FieldOracle, Operator: OpContains, Value: "x<=y"}}},
+		{"o:power>=4", []Term{{Field: FieldOracle, Operator: OpContains, Value: "power>=4"}}},
+		{"name=a:b", []Term{{Field: FieldName, Operator: OpContains, Value: "a:b"}}},
+		{`o:"draw a card"`, []Term{{Field: FieldOracle, Operator: OpContains, Value: "draw a card"}}},
+		{`"Llanowar Elves"`, []Term{{Field: FieldName, Operator: OpContains, Value: "llanowar elves"}}},
+		{"t:Creature c:rg r:m s:DOM", []Term{
+			{Field: FieldType, Operator: OpContains, Value: "creature"},
+			{Field: FieldColors, Operator: OpContains, Value: "RG"},
+			{Field: FieldRarity, Operator: OpEqual, Value: "mythic"},
+			{Field: FieldSet, Operator: OpEqual, Value: "dom"},
+		}},
+		{"c:green c:wuw", []Term{
+			{Field: FieldColors, Operator: OpContains, Value: "G"},
+			{Field: FieldColors, Operator: OpContains, Value: "WU"},
+		}},
+		{"goblin a:guay", []Term{
+			{Field: FieldName, Operator: OpContains, Value: "goblin"},
+			{Field: FieldArtist, Operator: OpContains, Value: "guay"},
+		}},
+		{"  ", []Term{}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		`"unterminated`,
+		"-t:creature",
+		"t:",
+		"foo:bar",
+		"cmc<=x",
+		"c>=rg",
+		"c:xyz",
+		"r:legendary",
+		"t>creature",
+	} {
+		if terms, err := Parse(query); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", query, terms)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	rest, terms, err := Extract(`flying artist:"rebecca guay" t:creature`, FieldArtist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest != "flying t:creature" {
+		t.Errorf("rest = %q", rest)
+	}
+	want := []Term{{Field: FieldArtist, Operator: OpContains, Value: "rebecca guay"}}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("terms = %+v, want %+v", terms, want)
+	}
+
+	rest, terms, err = Extract("cards that fly", FieldArtist)
+	if err != nil || rest != "cards that fly" || terms != nil {
+		t.Errorf("Extract without artist terms = %q, %+v, %v", rest, terms, err)
+	}
+}
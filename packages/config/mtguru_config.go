@@ -9,10 +9,89 @@ import (
 	"github.com/pelletier/go-toml/v2"
 )
 
+// CORSConfig describes which browser origins may call the server.
+type CORSConfig struct {
+	ALLOWED_ORIGINS   []string `toml:"ALLOWED_ORIGINS"`
+	ALLOWED_METHODS   []string `toml:"ALLOWED_METHODS"`
+	ALLOWED_HEADERS   []string `toml:"ALLOWED_HEADERS"`
+	ALLOW_CREDENTIALS bool     `toml:"ALLOW_CREDENTIALS"`
+	MAX_AGE_SECONDS   int      `toml:"MAX_AGE_SECONDS"`
+}
+
+// LLMConfig selects the model used by the /api/ask endpoint. PROVIDER is
+// "openai" for any OpenAI-compatible /chat/completions endpoint or "stub" for
+// the deterministic offline answerer.
+type LLMConfig struct {
+	PROVIDER string `toml:"PROVIDER"`
+	BASE_URL string `toml:"BASE_URL"`
+	MODEL    string `toml:"MODEL"`
+	// API_KEY defaults to OPEN_API_KEY when empty
+	API_KEY string `toml:"API_KEY"`
+}
+
+// AnalyticsConfig controls the search analytics log. Zero values use the
+// analytics package defaults and the "analytics" directory.
+type AnalyticsConfig struct {
+	DISABLED    bool   `toml:"DISABLED"`
+	DIR         string `toml:"DIR"`
+	MAX_FILE_MB int    `toml:"MAX_FILE_MB"`
+	MAX_FILES   int    `toml:"MAX_FILES"`
+	// POOR_DISTANCE is the best-hit distance above which a query is reported as a poor match
+	POOR_DISTANCE float64 `toml:"POOR_DISTANCE"`
+}
+
+// RerankConfig controls the reranking stage after retrieval. DEFAULT is the
+// reranker used when a search names none: "none", "heuristic" or
+// "cross_encoder", which needs URL.
+type RerankConfig struct {
+	DEFAULT string `toml:"DEFAULT"`
+	// URL is a cross-encoder /rerank endpoint
+	URL        string `toml:"URL"`
+	TIMEOUT_MS int    `toml:"TIMEOUT_MS"`
+	// CANDIDATES is how many hits are retrieved for reranking. Zero uses 100.
+	CANDIDATES int `toml:"CANDIDATES"`
+	// BATCH_SIZE is the most texts sent to the cross-encoder per request.
+	// Zero uses 32, text-embeddings-inference's default maximum.
+	BATCH_SIZE int `toml:"BATCH_SIZE"`
+}
+
+// ImagesConfig controls the card image cache behind /api/images. Zero values
+// use the "images" directory, a 1024 MB cache, 10 MB images and Scryfall.
+type ImagesConfig struct {
+	DISABLED bool   `toml:"DISABLED"`
+	DIR      string `toml:"DIR"`
+	MAX_MB   int    `toml:"MAX_MB"`
+	// MAX_IMAGE_MB rejects larger upstream responses
+	MAX_IMAGE_MB int `toml:"MAX_IMAGE_MB"`
+	// UPSTREAM serves <UPSTREAM>/<size>/front/<a>/<b>/<id>.<ext> like cards.scryfall.io
+	UPSTREAM   string `toml:"UPSTREAM"`
+	TIMEOUT_MS int    `toml:"TIMEOUT_MS"`
+}
+
 type EnvironmentConfig struct {
 	WEAVIATE_URL     string `toml:"WEAVIATE_URL"`
 	WEAVIATE_API_KEY string `toml:"WEAVIATE_API_KEY"`
 	OPEN_API_KEY     string `toml:"OPEN_API_KEY"`
+	// SEARCH_TIMEOUT_MS bounds a single search against Weaviate. Zero uses the server default.
+	SEARCH_TIMEOUT_MS int `toml:"SEARCH_TIMEOUT_MS"`
+	// BATCH_SEARCH_CONCURRENCY caps parallel Weaviate queries per batch request. Zero uses the server default.
+	BATCH_SEARCH_CONCURRENCY int `toml:"BATCH_SEARCH_CONCURRENCY"`
+	// STORE_PATH is the embedded database file for saved searches and favorites. Empty uses mtguru.db.
+	STORE_PATH string `toml:"STORE_PATH"`
+	// SYNONYMS_PATH is an optional TOML dictionary of query expansions merged over the built-in one
+	SYNONYMS_PATH string `toml:"SYNONYMS_PATH"`
+	// ADMIN_TOKEN is the bearer token for /api/admin endpoints, which are disabled when it is empty
+	ADMIN_TOKEN string `toml:"ADMIN_TOKEN"`
+	// CORS is read from the [<env>.cors] table
+	CORS CORSConfig `toml:"cors"`
+	// LLM is read from the [<env>.llm] table
+	LLM LLMConfig `toml:"llm"`
+	// ANALYTICS is read from the [<env>.analytics] table
+	ANALYTICS AnalyticsConfig `toml:"analytics"`
+	// RERANK is read from the [<env>.rerank] table
+	RERANK RerankConfig `toml:"rerank"`
+	// IMAGES is read from the [<env>.images] table
+	IMAGES ImagesConfig `toml:"images"`
 }
 
 type Environments struct {
@@ -61,6 +140,15 @@ func CreateConfig() EnvironmentConfig {
 	slog.Info("WEAVIATE_URL", "weaviate_url", activeConfig.WEAVIATE_URL)
 	slog.Info("WEAVIATE_API_KEY:", "weaviate_api_key", activeConfig.WEAVIATE_API_KEY)
 	slog.Info("OPEN_API_KEY:", "open_api_key", activeConfig.OPEN_API_KEY)
+	slog.Info("SEARCH_TIMEOUT_MS:", "search_timeout_ms", activeConfig.SEARCH_TIMEOUT_MS)
+	slog.Info("STORE_PATH:", "store_path", activeConfig.STORE_PATH)
+	slog.Info("SYNONYMS_PATH:", "synonyms_path", activeConfig.SYNONYMS_PATH)
+	slog.Info("ADMIN_TOKEN:", "configured", activeConfig.ADMIN_TOKEN != "")
+	slog.Info("ANALYTICS:", "disabled", activeConfig.ANALYTICS.DISABLED, "dir", activeConfig.ANALYTICS.DIR)
+	slog.Info("RERANK:", "default", activeConfig.RERANK.DEFAULT, "url", activeConfig.RERANK.URL, "candidates", activeConfig.RERANK.CANDIDATES)
+	slog.Info("IMAGES:", "disabled", activeConfig.IMAGES.DISABLED, "dir", activeConfig.IMAGES.DIR, "max_mb", activeConfig.IMAGES.MAX_MB, "upstream", activeConfig.IMAGES.UPSTREAM)
+	slog.Info("CORS:", "allowed_origins", activeConfig.CORS.ALLOWED_ORIGINS)
+	slog.Info("LLM:", "provider", activeConfig.LLM.PROVIDER, "base_url", activeConfig.LLM.BASE_URL, "model", activeConfig.LLM.MODEL)
 
 	return activeConfig
 }
@@ -11,6 +11,23 @@ import (
 	"github.com/fatih/color"
 )
 
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the given request ID. Records
+// logged with that context get a "request_id" field.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
 type PrettyHandlerOptions struct {
 	SlogOpts slog.HandlerOptions
 }
@@ -39,6 +56,9 @@ func (h *PrettyHandler) Handle(ctx context.Context, r slog.Record) error {
 		fields[a.Key] = a.Value.Any()
 		return true
 	})
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fields["request_id"] = requestID
+	}
 
 	b, err := json.MarshalIndent(fields, "", "  ")
 	if err != nil {
@@ -0,0 +1,67 @@
+package decklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Arena groups entries under these headers, in this order.
+var arenaSections = []struct {
+	section Section
+	header  string
+}{
+	{SectionCommander, "Commander"},
+	{SectionCompanion, "Companion"},
+	{SectionMain, "Deck"},
+	{SectionSideboard, "Sideboard"},
+}
+
+// WriteText writes the plain "<quantity> <name>" format with a Sideboard
+// header, which every deckbuilder accepts.
+func WriteText(w io.Writer, deck Decklist) error {
+	return writeSections(w, deck, false)
+}
+
+// WriteArena writes an MTG Arena export. Set code and collector number are
+// included when known so Arena imports the same printing.
+func WriteArena(w io.Writer, deck Decklist) error {
+	return writeSections(w, deck, true)
+}
+
+func writeSections(w io.Writer, deck Decklist, arena bool) error {
+	buf := bufio.NewWriter(w)
+	first := true
+	for _, group := range arenaSections {
+		var lines []string
+		for _, entry := range deck.Entries {
+			if entry.Section != group.section {
+				continue
+			}
+			line := fmt.Sprintf("%d %s", entry.Quantity, entry.Name)
+			if arena && entry.SetCode != "" {
+				line += fmt.Sprintf(" (%s)", strings.ToUpper(entry.SetCode))
+				if entry.CollectorNumber != "" {
+					line += " " + entry.CollectorNumber
+				}
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(buf)
+		}
+		// Plain text lists conventionally start straight with the main deck
+		if arena || group.section != SectionMain || !first {
+			fmt.Fprintln(buf, group.header)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(buf, line)
+		}
+		first = false
+	}
+	return buf.Flush()
+}
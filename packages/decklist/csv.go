@@ -0,0 +1,149 @@
+package decklist
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Column names accepted when reading CSV exports. Moxfield and Archidekt
+// disagree on most of them, so each field lists every known spelling.
+var csvColumnAliases = map[string][]string{
+	"quantity":         {"count", "quantity", "qty"},
+	"name":             {"name", "card name", "card"},
+	"set_code":         {"edition", "edition code", "set code", "set"},
+	"collector_number": {"collector number", "collector #", "collector_number", "number"},
+	"mtgo_id":          {"mtgo id", "mtgo_id"},
+	"section":          {"board", "category", "categories", "section"},
+}
+
+var moxfieldColumns = []string{"Count", "Name", "Edition", "Collector Number", "Board"}
+var archidektColumns = []string{"Quantity", "Name", "Edition Code", "Collector Number", "Category"}
+
+func csvSection(value string) Section {
+	// Archidekt allows several comma separated categories; the first decides the board
+	first, _, _ := strings.Cut(value, ",")
+	switch strings.ToLower(strings.TrimSpace(first)) {
+	case "sideboard", "side":
+		return SectionSideboard
+	case "commander":
+		return SectionCommander
+	case "companion":
+		return SectionCompanion
+	case "maybeboard", "maybe", "considering":
+		return SectionMaybeboard
+	}
+	return SectionMain
+}
+
+// csvColumns maps each field of csvColumnAliases to the index of the first
+// header cell spelling it.
+func csvColumns(header []string) map[string]int {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for field, aliases := range csvColumnAliases {
+			for _, alias := range aliases {
+				if _, taken := columns[field]; name == alias && !taken {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	return columns
+}
+
+// ParseCSV reads a Moxfield or Archidekt CSV export, locating columns by
+// their header names.
+func ParseCSV(r io.Reader) (Decklist, []ParseError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return Decklist{}, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	columns := csvColumns(header)
+	if _, ok := columns["name"]; !ok {
+		return Decklist{}, nil, fmt.Errorf("invalid CSV: no Name column in header")
+	}
+
+	get := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var deck Decklist
+	var errs []ParseError
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			errs = append(errs, ParseError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		name := get(record, "name")
+		if name == "" {
+			continue
+		}
+		quantity := 1
+		if raw := get(record, "quantity"); raw != "" {
+			quantity, err = strconv.Atoi(raw)
+			if err != nil || quantity <= 0 {
+				errs = append(errs, ParseError{Line: line, Text: strings.Join(record, ","), Message: fmt.Sprintf("invalid quantity %q", raw)})
+				continue
+			}
+		}
+		mtgoID, _ := strconv.Atoi(get(record, "mtgo_id"))
+
+		deck.Entries = append(deck.Entries, Entry{
+			Quantity:        quantity,
+			Name:            name,
+			Section:         csvSection(get(record, "section")),
+			SetCode:         strings.ToLower(get(record, "set_code")),
+			CollectorNumber: get(record, "collector_number"),
+			MtgoID:          mtgoID,
+			Line:            line,
+		})
+	}
+	return deck, errs, nil
+}
+
+// WriteCSV writes the entries using the given header. Columns follow the
+// order quantity, name, set, collector number, section.
+func WriteCSV(w io.Writer, deck Decklist, columns []string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, entry := range deck.Entries {
+		section := string(entry.Section)
+		if entry.Section == SectionMain {
+			section = "mainboard"
+		}
+		err := writer.Write([]string{
+			strconv.Itoa(entry.Quantity),
+			entry.Name,
+			strings.ToLower(entry.SetCode),
+			entry.CollectorNumber,
+			section,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,280 @@
+package decklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Section string
+
+const (
+	SectionMain       Section = "main"
+	SectionSideboard  Section = "sideboard"
+	SectionCommander  Section = "commander"
+	SectionCompanion  Section = "companion"
+	SectionMaybeboard Section = "maybeboard"
+)
+
+// Entry is one line of a decklist. SetCode, CollectorNumber and MtgoID are
+// only set when the source format carries them (e.g. "1 Opt (ELD) 59").
+type Entry struct {
+	Quantity        int     `json:"quantity"`
+	Name            string  `json:"name"`
+	Section         Section `json:"section"`
+	SetCode         string  `json:"set_code,omitempty"`
+	CollectorNumber string  `json:"collector_number,omitempty"`
+	MtgoID          int     `json:"mtgo_id,omitempty"`
+	Line            int     `json:"line,omitempty"`
+}
+
+type Decklist struct {
+	Entries []Entry `json:"entries"`
+}
+
+type ParseError struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Message string `json:"message"`
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s (%q)", e.Line, e.Message, e.Text)
+}
+
+// Count returns the number of cards in a section.
+func (d Decklist) Count(section Section) int {
+	total := 0
+	for _, entry := range d.Entries {
+		if entry.Section == section {
+			total += entry.Quantity
+		}
+	}
+	return total
+}
+
+// Names returns every distinct card name in the list, in first-seen order.
+func (d Decklist) Names() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, entry := range d.Entries {
+		key := strings.ToLower(entry.Name)
+		if !seen[key] {
+			seen[key] = true
+			names = append(names, entry.Name)
+		}
+	}
+	return names
+}
+
+var (
+	// "4 Lightning Bolt", "4x Lightning Bolt", "1 Opt (ELD) 59", "1 Opt [ELD]"
+	entryPattern = regexp.MustCompile(`^(\d+)\s*[xX]?\s+(.+?)(?:\s+[(\[]([A-Za-z0-9]{2,6})[)\]](?:\s+(\S+))?)?$`)
+	// Arena and MTGO mark foils and other printings with trailing tags like *F*
+	trailingTagPattern = regexp.MustCompile(`\s+\*[A-Za-z]+\*$`)
+)
+
+var sectionHeaders = map[string]Section{
+	"deck":        SectionMain,
+	"main":        SectionMain,
+	"mainboard":   SectionMain,
+	"main deck":   SectionMain,
+	"sideboard":   SectionSideboard,
+	"side":        SectionSideboard,
+	"commander":   SectionCommander,
+	"commanders":  SectionCommander,
+	"companion":   SectionCompanion,
+	"maybeboard":  SectionMaybeboard,
+	"considering": SectionMaybeboard,
+}
+
+// Deckbuilders that group the main deck by card type put a header over each
+// group, often with a card count: "Creatures (20)", "Lands".
+var categoryHeaders = map[string]bool{
+	"creature":      true,
+	"creatures":     true,
+	"instant":       true,
+	"instants":      true,
+	"sorcery":       true,
+	"sorceries":     true,
+	"artifact":      true,
+	"artifacts":     true,
+	"enchantment":   true,
+	"enchantments":  true,
+	"planeswalker":  true,
+	"planeswalkers": true,
+	"battle":        true,
+	"battles":       true,
+	"land":          true,
+	"lands":         true,
+	"spells":        true,
+	"other":         true,
+}
+
+// headerCountPattern matches the card count after a header, "Sideboard (15)"
+var headerCountPattern = regexp.MustCompile(`\s*\(\d+\)$`)
+
+// headerKey normalises the text before a header's colon for lookup in
+// sectionHeaders and categoryHeaders.
+func headerKey(header string) string {
+	return strings.ToLower(headerCountPattern.ReplaceAllString(strings.TrimSpace(header), ""))
+}
+
+// isHeader reports whether a line names a section or category, including the
+// inline "Commander: <name>" and "SB: <entry>" forms.
+func isHeader(line string) bool {
+	header, rest, hasColon := strings.Cut(line, ":")
+	key := headerKey(header)
+	if _, ok := sectionHeaders[key]; ok {
+		return true
+	}
+	if categoryHeaders[key] && strings.TrimSpace(rest) == "" {
+		return true
+	}
+	return hasColon && key == "sb"
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#")
+}
+
+// ParseText parses a plain text decklist as pasted from most deckbuilders. It
+// understands section headers ("Sideboard", "Commander:"), category headers
+// ("Creatures (20)"), "SB:" prefixes, a "Commander: <name>" line and comments
+// starting with // or #. A list without any headers that a blank line splits
+// into exactly two groups is a Magic Online export, and the second group is
+// its sideboard. Lines that cannot be parsed are returned as errors; the rest
+// of the list is still parsed.
+func ParseText(r io.Reader) (Decklist, []ParseError) {
+	return parseText(r, true)
+}
+
+// ParseArena parses an MTG Arena export. Arena always writes section headers,
+// so blank lines only space the sections out.
+func ParseArena(r io.Reader) (Decklist, []ParseError) {
+	return parseText(r, false)
+}
+
+func parseText(r io.Reader, blankLineSideboard bool) (Decklist, []ParseError) {
+	var deck Decklist
+	var errs []ParseError
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, ParseError{Line: len(lines) + 1, Message: err.Error()})
+	}
+
+	sideboardFrom := -1
+	if blankLineSideboard {
+		sideboardFrom = mtgoSideboardStart(lines)
+	}
+
+	section := SectionMain
+	for i, text := range lines {
+		lineNumber := i + 1
+		line := strings.TrimSpace(text)
+		if line == "" || isComment(line) {
+			continue
+		}
+		if i == sideboardFrom {
+			section = SectionSideboard
+		}
+
+		lineSection := section
+		header, rest, hasColon := strings.Cut(line, ":")
+		rest = strings.TrimSpace(rest)
+		key := headerKey(header)
+		if headerSection, ok := sectionHeaders[key]; ok {
+			if !hasColon || rest == "" {
+				section = headerSection
+				continue
+			}
+			// "Commander: Atraxa, Praetors' Voice" names a single card inline
+			lineSection = headerSection
+			line = rest
+		} else if categoryHeaders[key] && rest == "" {
+			section = SectionMain
+			continue
+		} else if key == "sb" && hasColon {
+			lineSection = SectionSideboard
+			line = rest
+		}
+
+		entry, err := parseEntry(line)
+		if err != nil {
+			errs = append(errs, ParseError{Line: lineNumber, Text: text, Message: err.Error()})
+			continue
+		}
+		entry.Section = lineSection
+		entry.Line = lineNumber
+		deck.Entries = append(deck.Entries, entry)
+	}
+
+	return deck, errs
+}
+
+// mtgoSideboardStart returns the index of the first sideboard line of a
+// Magic Online text export, or -1 if lines do not look like one: MTGO writes
+// no headers and separates the main deck from the sideboard with a blank
+// line, so any header, or more than one blank-line break, rules it out.
+func mtgoSideboardStart(lines []string) int {
+	start := -1
+	groups := 0
+	inGroup := false
+	for i, text := range lines {
+		line := strings.TrimSpace(text)
+		switch {
+		case line == "":
+			inGroup = false
+		case isComment(line):
+		case isHeader(line):
+			return -1
+		case !inGroup:
+			inGroup = true
+			groups++
+			if groups == 2 {
+				start = i
+			}
+		}
+	}
+	if groups != 2 {
+		return -1
+	}
+	return start
+}
+
+func parseEntry(line string) (Entry, error) {
+	line = trailingTagPattern.ReplaceAllString(line, "")
+
+	match := entryPattern.FindStringSubmatch(line)
+	if match == nil {
+		// A bare name counts as a single copy, as in "Commander: <name>"
+		if line != "" && !startsWithDigit(line) {
+			return Entry{Quantity: 1, Name: line}, nil
+		}
+		return Entry{}, fmt.Errorf("expected \"<quantity> <card name>\"")
+	}
+
+	quantity, err := strconv.Atoi(match[1])
+	if err != nil || quantity <= 0 {
+		return Entry{}, fmt.Errorf("invalid quantity %q", match[1])
+	}
+
+	return Entry{
+		Quantity:        quantity,
+		Name:            strings.TrimSpace(match[2]),
+		SetCode:         strings.ToLower(match[3]),
+		CollectorNumber: match[4],
+	}, nil
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
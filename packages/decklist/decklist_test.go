@@ -0,0 +1,112 @@
+package decklist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// entry is shorthand for the fields the text tests care about.
+type entry struct {
+	quantity int
+	name     string
+	section  Section
+}
+
+func entriesOf(deck Decklist) []entry {
+	entries := []entry{}
+	for _, e := range deck.Entries {
+		entries = append(entries, entry{e.Quantity, e.Name, e.Section})
+	}
+	return entries
+}
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []entry
+	}{
+		{
+			name: "quantities",
+			text: "4 Lightning Bolt\n4x Opt\n1 X Counterspell",
+			want: []entry{{4, "Lightning Bolt", SectionMain}, {4, "Opt", SectionMain}, {1, "Counterspell", SectionMain}},
+		},
+		{
+			name: "MTGO blank line sideboard",
+			text: "4 Lightning Bolt\n20 Mountain\n\n3 Smash to Smithereens",
+			want: []entry{{4, "Lightning Bolt", SectionMain}, {20, "Mountain", SectionMain}, {3, "Smash to Smithereens", SectionSideboard}},
+		},
+		{
+			name: "trailing blank lines are not a sideboard",
+			text: "4 Lightning Bolt\n20 Mountain\n\n\n",
+			want: []entry{{4, "Lightning Bolt", SectionMain}, {20, "Mountain", SectionMain}},
+		},
+		{
+			name: "several blank-line groups stay in the main deck",
+			text: "4 Goblin Guide\n\n4 Lightning Bolt\n\n20 Mountain",
+			want: []entry{{4, "Goblin Guide", SectionMain}, {4, "Lightning Bolt", SectionMain}, {20, "Mountain", SectionMain}},
+		},
+		{
+			name: "category headers",
+			text: "Creatures (4)\n4 Goblin Guide\n\nInstants (4)\n4 Lightning Bolt\n\nLands\n20 Mountain\n\nSideboard (3)\n3 Smash to Smithereens",
+			want: []entry{
+				{4, "Goblin Guide", SectionMain},
+				{4, "Lightning Bolt", SectionMain},
+				{20, "Mountain", SectionMain},
+				{3, "Smash to Smithereens", SectionSideboard},
+			},
+		},
+		{
+			name: "headers rule out the blank-line sideboard",
+			text: "Deck\n4 Lightning Bolt\n\n20 Mountain",
+			want: []entry{{4, "Lightning Bolt", SectionMain}, {20, "Mountain", SectionMain}},
+		},
+		{
+			name: "inline commander and SB prefix",
+			text: "Commander: Krenko, Mob Boss\n\n1 Goblin Guide\nSB: 2 Pyroblast",
+			want: []entry{{1, "Krenko, Mob Boss", SectionCommander}, {1, "Goblin Guide", SectionMain}, {2, "Pyroblast", SectionSideboard}},
+		},
+		{
+			name: "comments and tags",
+			text: "// Burn\n# main\n4 Lightning Bolt *F*",
+			want: []entry{{4, "Lightning Bolt", SectionMain}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deck, errs := ParseText(strings.NewReader(test.text))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if got := entriesOf(deck); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseArenaIgnoresBlankLines(t *testing.T) {
+	deck, errs := ParseArena(strings.NewReader("4 Lightning Bolt\n\n20 Mountain"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := []entry{{4, "Lightning Bolt", SectionMain}, {20, "Mountain", SectionMain}}
+	if got := entriesOf(deck); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseTextPrintingsAndErrors(t *testing.T) {
+	deck, errs := ParseText(strings.NewReader("1 Opt (ELD) 59\n0 Opt\n1 Island [M21]"))
+	want := []Entry{
+		{Quantity: 1, Name: "Opt", Section: SectionMain, SetCode: "eld", CollectorNumber: "59", Line: 1},
+		{Quantity: 1, Name: "Island", Section: SectionMain, SetCode: "m21", Line: 3},
+	}
+	if !reflect.DeepEqual(deck.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", deck.Entries, want)
+	}
+	if len(errs) != 1 || errs[0].Line != 2 {
+		t.Errorf("errs = %v, want one error on line 2", errs)
+	}
+}
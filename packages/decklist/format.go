@@ -0,0 +1,101 @@
+package decklist
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format names a decklist file format understood by Parse and Write.
+type Format string
+
+const (
+	FormatText      Format = "text"
+	FormatArena     Format = "arena"
+	FormatMTGO      Format = "mtgo"
+	FormatMoxfield  Format = "moxfield"
+	FormatArchidekt Format = "archidekt"
+)
+
+// ContentType returns the MIME type usually used for files of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatMTGO:
+		return "application/xml"
+	case FormatMoxfield, FormatArchidekt:
+		return "text/csv"
+	default:
+		return "text/plain"
+	}
+}
+
+// Detect guesses the format of a decklist from its content: MTGO .dek files
+// are XML and the CSV exports start with a header row naming at least the
+// card name and quantity columns.
+func Detect(content []byte) Format {
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return FormatMTGO
+	}
+
+	firstLine, _, _ := strings.Cut(string(trimmed), "\n")
+	if header, err := csv.NewReader(strings.NewReader(firstLine)).Read(); err == nil && len(header) > 1 {
+		// A real export header names both the card and its quantity column
+		columns := csvColumns(header)
+		_, hasName := columns["name"]
+		_, hasQuantity := columns["quantity"]
+		if hasName && hasQuantity {
+			for _, cell := range header {
+				switch strings.ToLower(strings.TrimSpace(cell)) {
+				case "quantity", "category", "categories", "edition code":
+					return FormatArchidekt
+				}
+			}
+			return FormatMoxfield
+		}
+	}
+
+	if strings.Contains(string(trimmed), "\nDeck\n") || strings.HasPrefix(string(trimmed), "Deck\n") {
+		return FormatArena
+	}
+	return FormatText
+}
+
+// Parse reads a decklist in the given format. Per-line problems are returned
+// as ParseErrors alongside whatever could be parsed; the error is only set
+// when the input as a whole is unreadable.
+func Parse(format Format, r io.Reader) (Decklist, []ParseError, error) {
+	switch format {
+	case FormatText:
+		deck, errs := ParseText(r)
+		return deck, errs, nil
+	case FormatArena:
+		deck, errs := ParseArena(r)
+		return deck, errs, nil
+	case FormatMTGO:
+		deck, err := ParseMTGO(r)
+		return deck, nil, err
+	case FormatMoxfield, FormatArchidekt:
+		return ParseCSV(r)
+	}
+	return Decklist{}, nil, fmt.Errorf("unknown decklist format %q", format)
+}
+
+// Write serialises a decklist in the given format.
+func Write(format Format, w io.Writer, deck Decklist) error {
+	switch format {
+	case FormatText:
+		return WriteText(w, deck)
+	case FormatArena:
+		return WriteArena(w, deck)
+	case FormatMTGO:
+		return WriteMTGO(w, deck)
+	case FormatMoxfield:
+		return WriteCSV(w, deck, moxfieldColumns)
+	case FormatArchidekt:
+		return WriteCSV(w, deck, archidektColumns)
+	}
+	return fmt.Errorf("unknown decklist format %q", format)
+}
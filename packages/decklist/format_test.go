@@ -0,0 +1,125 @@
+package decklist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Format
+	}{
+		{"mtgo xml", `<?xml version="1.0"?><Deck></Deck>`, FormatMTGO},
+		{"moxfield csv", "\"Count\",\"Tradelist Count\",\"Name\",\"Edition\"\n1,0,Opt,eld", FormatMoxfield},
+		{"archidekt csv", "Quantity,Name,Edition Code,Category\n1,Opt,eld,Main", FormatArchidekt},
+		{"name column without quantity", "Name,Notes\nOpt,cheap", FormatText},
+		{"text line with a comma and name", "4 Lightning Bolt, name\n2 Opt", FormatText},
+		{"arena", "Deck\n4 Lightning Bolt", FormatArena},
+		{"arena after commander", "Commander\n1 Krenko, Mob Boss\n\nDeck\n4 Goblin Guide", FormatArena},
+		{"plain text", "4 Lightning Bolt", FormatText},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Detect([]byte(test.content)); got != test.want {
+				t.Errorf("Detect = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	content := "Quantity,Name,Edition Code,Collector Number,MTGO ID,Category\n" +
+		"4,Lightning Bolt,M10,146,31745,Main\n" +
+		"2,Pyroblast,ICE,213,,\"Sideboard,Hate\"\n" +
+		"x,Opt,ELD,59,,Main\n"
+	deck, errs, err := ParseCSV(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Quantity: 4, Name: "Lightning Bolt", Section: SectionMain, SetCode: "m10", CollectorNumber: "146", MtgoID: 31745, Line: 2},
+		{Quantity: 2, Name: "Pyroblast", Section: SectionSideboard, SetCode: "ice", CollectorNumber: "213", Line: 3},
+	}
+	if !reflect.DeepEqual(deck.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", deck.Entries, want)
+	}
+	if len(errs) != 1 || errs[0].Line != 4 {
+		t.Errorf("errs = %v, want one error on line 4", errs)
+	}
+
+	if _, _, err := ParseCSV(strings.NewReader("Count,Edition\n1,eld")); err == nil {
+		t.Error("ParseCSV without a Name column succeeded")
+	}
+}
+
+func TestWriteMTGOOmitsMissingCatID(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteMTGO(&out, Decklist{Entries: []Entry{
+		{Quantity: 4, Name: "Lightning Bolt", Section: SectionMain, MtgoID: 31745},
+		{Quantity: 1, Name: "Opt", Section: SectionMain},
+		{Quantity: 1, Name: "Maybe", Section: SectionMaybeboard},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := out.String()
+	if !strings.Contains(written, `CatID="31745"`) {
+		t.Errorf("missing CatID for Lightning Bolt:\n%s", written)
+	}
+	if strings.Contains(written, `CatID="0"`) {
+		t.Errorf("wrote CatID 0:\n%s", written)
+	}
+	if strings.Contains(written, "Maybe") {
+		t.Errorf("wrote the maybeboard:\n%s", written)
+	}
+}
+
+// roundTripDeck has what every format can carry: quantities, names and the
+// main deck and sideboard.
+var roundTripDeck = Decklist{Entries: []Entry{
+	{Quantity: 4, Name: "Lightning Bolt", Section: SectionMain, SetCode: "m10", CollectorNumber: "146", MtgoID: 31745},
+	{Quantity: 20, Name: "Mountain", Section: SectionMain, SetCode: "m21", CollectorNumber: "272", MtgoID: 81265},
+	{Quantity: 3, Name: "Smash to Smithereens", Section: SectionSideboard, SetCode: "ori", CollectorNumber: "163", MtgoID: 57911},
+}}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatText, FormatArena, FormatMTGO, FormatMoxfield, FormatArchidekt} {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Write(format, &out, roundTripDeck); err != nil {
+				t.Fatal(err)
+			}
+			if detected := Detect(out.Bytes()); detected != format {
+				t.Errorf("Detect = %s, want %s:\n%s", detected, format, out.String())
+			}
+			deck, errs, err := Parse(format, &out)
+			if err != nil || len(errs) > 0 {
+				t.Fatalf("Parse: %v %v", err, errs)
+			}
+
+			for i, got := range deck.Entries {
+				want := roundTripDeck.Entries[i]
+				if got.Quantity != want.Quantity || got.Name != want.Name || got.Section != want.Section {
+					t.Errorf("entry %d = %+v, want %+v", i, got, want)
+				}
+				switch format {
+				case FormatArena, FormatMoxfield, FormatArchidekt:
+					if got.SetCode != want.SetCode || got.CollectorNumber != want.CollectorNumber {
+						t.Errorf("entry %d printing = %s %s, want %s %s", i, got.SetCode, got.CollectorNumber, want.SetCode, want.CollectorNumber)
+					}
+				case FormatMTGO:
+					if got.MtgoID != want.MtgoID {
+						t.Errorf("entry %d MTGO id = %d, want %d", i, got.MtgoID, want.MtgoID)
+					}
+				}
+			}
+			if len(deck.Entries) != len(roundTripDeck.Entries) {
+				t.Errorf("got %d entries, want %d", len(deck.Entries), len(roundTripDeck.Entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package decklist
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// mtgoDeck mirrors the .dek XML written by Magic Online.
+type mtgoDeck struct {
+	XMLName              xml.Name   `xml:"Deck"`
+	NetDeckID            int        `xml:"NetDeckID"`
+	PreconstructedDeckID int        `xml:"PreconstructedDeckID"`
+	Cards                []mtgoCard `xml:"Cards"`
+}
+
+type mtgoCard struct {
+	CatID      int    `xml:"CatID,attr,omitempty"`
+	Quantity   int    `xml:"Quantity,attr"`
+	Sideboard  bool   `xml:"Sideboard,attr"`
+	Name       string `xml:"Name,attr"`
+	Annotation int    `xml:"Annotation,attr"`
+}
+
+// ParseMTGO reads a Magic Online .dek file. CatID is kept as the entry's
+// MtgoID so the card can be resolved by id rather than by name.
+func ParseMTGO(r io.Reader) (Decklist, error) {
+	var dek mtgoDeck
+	if err := xml.NewDecoder(r).Decode(&dek); err != nil {
+		return Decklist{}, fmt.Errorf("invalid .dek file: %w", err)
+	}
+
+	var deck Decklist
+	for _, card := range dek.Cards {
+		section := SectionMain
+		if card.Sideboard {
+			section = SectionSideboard
+		}
+		deck.Entries = append(deck.Entries, Entry{
+			Quantity: card.Quantity,
+			Name:     card.Name,
+			Section:  section,
+			MtgoID:   card.CatID,
+		})
+	}
+	return deck, nil
+}
+
+// WriteMTGO writes a Magic Online .dek file. MTGO has no commander zone in
+// .dek files, so everything outside the sideboard goes in the main deck.
+// Entries without an MTGO id are written without a CatID, which MTGO then
+// resolves by name, rather than pointing at catalog id 0.
+func WriteMTGO(w io.Writer, deck Decklist) error {
+	dek := mtgoDeck{}
+	for _, entry := range deck.Entries {
+		if entry.Section == SectionMaybeboard {
+			continue
+		}
+		dek.Cards = append(dek.Cards, mtgoCard{
+			CatID:     entry.MtgoID,
+			Quantity:  entry.Quantity,
+			Sideboard: entry.Section == SectionSideboard || entry.Section == SectionCompanion,
+			Name:      entry.Name,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(dek); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
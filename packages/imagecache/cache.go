@@ -0,0 +1,251 @@
+// Package imagecache keeps card images in a content-addressed disk cache
+// with least-recently-used eviction, filled from an upstream Source.
+//
+// Blobs live at <dir>/blobs/<hash[:2]>/<sha256> so identical images are stored
+// once. Each cached key has a small ref file at <dir>/keys/<key> naming its
+// blob and content type; the ref's modification time is its last use, which
+// restores the LRU order after a restart.
+package imagecache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooLarge is returned for images larger than the cache or the upstream limit.
+var ErrTooLarge = errors.New("image is too large")
+
+// Entry describes one cached image.
+type Entry struct {
+	Key         string
+	Hash        string
+	ContentType string
+	Size        int64
+	// Stored is when the image was fetched
+	Stored time.Time
+}
+
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // key -> element holding *Entry
+	lru     *list.List               // front is most recently used
+	refs    map[string]int           // blob hash -> keys using it
+	size    int64                    // bytes of distinct blobs
+}
+
+// Open loads the cache in dir, creating it if needed, and evicts down to
+// maxBytes. Refs whose blob is missing and blobs no ref uses are removed.
+func Open(dir string, maxBytes int64) (*Cache, error) {
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		refs:     map[string]int{},
+	}
+	for _, sub := range []string{"keys", "blobs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	refFiles, err := os.ReadDir(filepath.Join(dir, "keys"))
+	if err != nil {
+		return nil, err
+	}
+	type loaded struct {
+		entry *Entry
+		used  time.Time
+	}
+	var entries []loaded
+	for _, file := range refFiles {
+		key, err := url.PathUnescape(file.Name())
+		if err != nil || file.IsDir() {
+			continue
+		}
+		entry, used, err := c.readRef(key)
+		if err != nil {
+			os.Remove(c.refPath(key))
+			continue
+		}
+		entries = append(entries, loaded{entry, used})
+	}
+
+	// Oldest first, so each PushFront leaves the newest at the front
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+	for _, l := range entries {
+		c.add(l.entry)
+	}
+	c.removeOrphanBlobs()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+func (c *Cache) refPath(key string) string {
+	return filepath.Join(c.dir, "keys", url.PathEscape(key))
+}
+
+func (c *Cache) blobPath(hash string) string {
+	return filepath.Join(c.dir, "blobs", hash[:2], hash)
+}
+
+// readRef parses a ref file: the blob hash, content type and fetch time in
+// RFC 3339 on separate lines.
+func (c *Cache) readRef(key string) (*Entry, time.Time, error) {
+	path := c.refPath(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 || len(lines[0]) != sha256.Size*2 {
+		return nil, time.Time{}, fmt.Errorf("malformed ref %s", path)
+	}
+	stored, err := time.Parse(time.RFC3339, lines[2])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	blob, err := os.Stat(c.blobPath(lines[0]))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	entry := &Entry{Key: key, Hash: lines[0], ContentType: lines[1], Size: blob.Size(), Stored: stored}
+	return entry, info.ModTime(), nil
+}
+
+func (c *Cache) removeOrphanBlobs() {
+	filepath.WalkDir(filepath.Join(c.dir, "blobs"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && c.refs[d.Name()] == 0 {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// add records an entry as most recently used. Callers hold mu or own c.
+func (c *Cache) add(entry *Entry) {
+	c.entries[entry.Key] = c.lru.PushFront(entry)
+	if c.refs[entry.Hash] == 0 {
+		c.size += entry.Size
+	}
+	c.refs[entry.Hash]++
+}
+
+// remove forgets key and deletes its ref, and its blob if no other key uses it.
+func (c *Cache) remove(element *list.Element) {
+	entry := element.Value.(*Entry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.Key)
+	os.Remove(c.refPath(entry.Key))
+
+	c.refs[entry.Hash]--
+	if c.refs[entry.Hash] <= 0 {
+		delete(c.refs, entry.Hash)
+		c.size -= entry.Size
+		os.Remove(c.blobPath(entry.Hash))
+	}
+}
+
+func (c *Cache) evict() {
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Get returns the entry for key and the path of its blob, marking it used.
+// The blob can be evicted before the caller opens it; treat that as a miss.
+func (c *Cache) Get(key string) (Entry, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return Entry{}, "", false
+	}
+	c.lru.MoveToFront(element)
+	now := time.Now()
+	os.Chtimes(c.refPath(key), now, now)
+
+	entry := *element.Value.(*Entry)
+	return entry, c.blobPath(entry.Hash), true
+}
+
+// Put stores data under key, replacing any previous image, and evicts the
+// least recently used images until the cache fits.
+func (c *Cache) Put(key string, data []byte, contentType string) (Entry, error) {
+	if int64(len(data)) > c.maxBytes {
+		return Entry{}, ErrTooLarge
+	}
+	sum := sha256.Sum256(data)
+	entry := &Entry{Key: key, Hash: hex.EncodeToString(sum[:]), ContentType: contentType, Size: int64(len(data)), Stored: time.Now().UTC().Truncate(time.Second)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop the previous image first; its blob is rewritten below if it is the same
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	if _, err := os.Stat(c.blobPath(entry.Hash)); err != nil {
+		if err := writeFileAtomic(c.blobPath(entry.Hash), data); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	ref := fmt.Sprintf("%s\n%s\n%s\n", entry.Hash, entry.ContentType, entry.Stored.Format(time.RFC3339))
+	if err := writeFileAtomic(c.refPath(key), []byte(ref)); err != nil {
+		return Entry{}, err
+	}
+	c.add(entry)
+	c.evict()
+	return *entry, nil
+}
+
+// Stats returns the number of cached keys and the bytes they use on disk.
+func (c *Cache) Stats() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len(), c.size
+}
+
+// writeFileAtomic writes through a temporary file so readers never see a
+// partial image.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,174 @@
+package imagecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func image(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 10)
+}
+
+func put(t *testing.T, c *Cache, key string, data []byte) {
+	t.Helper()
+	if _, err := c.Put(key, data, "image/jpeg"); err != nil {
+		t.Fatalf("Put(%s): %v", key, err)
+	}
+}
+
+func cached(c *Cache, keys ...string) []string {
+	var present []string
+	for _, key := range keys {
+		if _, _, ok := c.Get(key); ok {
+			present = append(present, key)
+		}
+	}
+	return present
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := Open(t.TempDir(), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "a", image('a'))
+	put(t, c, "b", image('b'))
+	put(t, c, "c", image('c'))
+	// Using a makes b the least recently used
+	if _, _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	put(t, c, "d", image('d'))
+
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	if _, path, ok := c.Get("a"); !ok {
+		t.Error("a was evicted")
+	} else if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, image('a')) {
+		t.Errorf("a blob = %q, %v", data, err)
+	}
+	if keys, size := c.Stats(); keys != 3 || size != 30 {
+		t.Errorf("Stats() = %d keys, %d bytes, want 3, 30", keys, size)
+	}
+}
+
+func TestSharedBlobCountedOnce(t *testing.T) {
+	c, err := Open(t.TempDir(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "front", image('x'))
+	put(t, c, "reprint", image('x'))
+	put(t, c, "other", image('y'))
+
+	if keys, size := c.Stats(); keys != 3 || size != 20 {
+		t.Errorf("Stats() = %d keys, %d bytes, want 3, 20", keys, size)
+	}
+	if got := cached(c, "front", "reprint", "other"); len(got) != 3 {
+		t.Errorf("cached = %v, want all three", got)
+	}
+}
+
+func TestPutTooLarge(t *testing.T) {
+	c, err := Open(t.TempDir(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Put("big", image('a'), "image/jpeg"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Put = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestOpenRestoresLRUOrder(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "old", image('o'))
+	put(t, c, "new", image('n'))
+	put(t, c, "mid", image('m'))
+
+	// Last use is the ref's modification time
+	now := time.Now()
+	for key, age := range map[string]time.Duration{"old": 3 * time.Hour, "mid": 2 * time.Hour, "new": time.Hour} {
+		used := now.Add(-age)
+		if err := os.Chtimes(c.refPath(key), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopening smaller evicts the oldest, then the next oldest
+	reopened, err := Open(dir, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cached(reopened, "old", "mid", "new"); len(got) != 2 || got[0] != "mid" || got[1] != "new" {
+		t.Errorf("after reopening at 20 bytes cached = %v, want [mid new]", got)
+	}
+
+	// Get records the use, so a reopen sees mid as the more recent
+	if _, _, ok := reopened.Get("mid"); !ok {
+		t.Fatal("mid missing")
+	}
+	used := now.Add(-time.Minute)
+	if err := os.Chtimes(c.refPath("new"), used, used); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err = Open(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cached(reopened, "mid", "new"); len(got) != 1 || got[0] != "mid" {
+		t.Errorf("after reopening at 10 bytes cached = %v, want [mid]", got)
+	}
+	if _, err := os.Stat(c.blobPath(hashOf(image('o')))); !os.IsNotExist(err) {
+		t.Errorf("evicted blob still on disk: %v", err)
+	}
+}
+
+func TestOpenDropsBrokenRefsAndOrphanBlobs(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "kept", image('k'))
+	put(t, c, "lost", image('l'))
+	if err := os.Remove(c.blobPath(hashOf(image('l')))); err != nil {
+		t.Fatal(err)
+	}
+	orphan := c.blobPath(hashOf(image('z')))
+	if err := writeFileAtomic(orphan, image('z')); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "keys", "garbage"), []byte("not a ref"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cached(reopened, "kept", "lost", "garbage"); len(got) != 1 || got[0] != "kept" {
+		t.Errorf("cached = %v, want [kept]", got)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("orphan blob still on disk: %v", err)
+	}
+	if _, err := os.Stat(reopened.refPath("lost")); !os.IsNotExist(err) {
+		t.Errorf("ref without blob still on disk: %v", err)
+	}
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package imagecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultUpstream is Scryfall's image host.
+const DefaultUpstream = "https://cards.scryfall.io"
+
+// ErrNotFound is returned when the upstream has no such image.
+var ErrNotFound = errors.New("image not found upstream")
+
+// Sizes maps each Scryfall image size to its file extension.
+var Sizes = map[string]string{
+	"small":       "jpg",
+	"normal":      "jpg",
+	"large":       "jpg",
+	"png":         "png",
+	"art_crop":    "jpg",
+	"border_crop": "jpg",
+}
+
+// Source fetches an image of a card by Scryfall id and size.
+type Source interface {
+	Fetch(ctx context.Context, scryfallID string, size string) (data []byte, contentType string, err error)
+}
+
+// HTTPSource fetches from a host laid out like cards.scryfall.io:
+// <BaseURL>/<size>/front/<id[0]>/<id[1]>/<id>.<ext>. A local file server
+// with the same layout stands in for Scryfall.
+type HTTPSource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxBytes rejects larger images with ErrTooLarge
+	MaxBytes int64
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context, scryfallID string, size string) ([]byte, string, error) {
+	ext, ok := Sizes[size]
+	if !ok || len(scryfallID) < 2 {
+		return nil, "", ErrNotFound
+	}
+	url := fmt.Sprintf("%s/%s/front/%c/%c/%s.%s", strings.TrimSuffix(s.BaseURL, "/"), size, scryfallID[0], scryfallID[1], scryfallID, ext)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("upstream returned %s for %s", response.Status, url)
+	}
+	if s.MaxBytes > 0 && response.ContentLength > s.MaxBytes {
+		return nil, "", ErrTooLarge
+	}
+
+	reader := io.Reader(response.Body)
+	if s.MaxBytes > 0 {
+		reader = io.LimitReader(response.Body, s.MaxBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.MaxBytes > 0 && int64(len(data)) > s.MaxBytes {
+		return nil, "", ErrTooLarge
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
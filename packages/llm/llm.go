@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LLM generates a completion for a chat transcript.
+type LLM interface {
+	Complete(ctx context.Context, messages []Message) (string, error)
+	Model() string
+}
+
+// Stub is a deterministic LLM for tests and offline development. It answers
+// by citing every numbered context line ("[1] ...") found in the last user
+// message, so callers can exercise citation handling without a model.
+type Stub struct{}
+
+var stubContextLine = regexp.MustCompile(`(?m)^\[(\d+)\]\s*([^\n(]+)`)
+
+func (Stub) Complete(ctx context.Context, messages []Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var last string
+	for _, message := range messages {
+		if message.Role == RoleUser {
+			last = message.Content
+		}
+	}
+
+	matches := stubContextLine.FindAllStringSubmatch(last, -1)
+	if len(matches) == 0 {
+		return "I could not find any relevant cards.", nil
+	}
+
+	parts := make([]string, 0, len(matches))
+	for _, match := range matches {
+		parts = append(parts, fmt.Sprintf("%s [%s]", strings.TrimSpace(match[2]), match[1]))
+	}
+	return "Relevant cards: " + strings.Join(parts, ", ") + ".", nil
+}
+
+func (Stub) Model() string {
+	return "stub"
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAICompatible calls a /chat/completions endpoint, which OpenAI and most
+// self-hosted servers (vLLM, Ollama, llama.cpp, LM Studio) implement.
+type OpenAICompatible struct {
+	BaseURL     string
+	APIKey      string
+	ModelName   string
+	Temperature float64
+	HTTPClient  *http.Client
+}
+
+// APIError is returned when the endpoint answers with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llm endpoint returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func NewOpenAICompatible(baseURL string, apiKey string, model string) *OpenAICompatible {
+	return &OpenAICompatible{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		ModelName:  model,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type chatCompletionRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+}
+
+func (o *OpenAICompatible) Complete(ctx context.Context, messages []Message) (string, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:       o.ModelName,
+		Messages:    messages,
+		Temperature: o.Temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+
+	res, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", &APIError{StatusCode: res.StatusCode, Body: string(responseBody)}
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(responseBody, &completion); err != nil {
+		return "", fmt.Errorf("invalid completion response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("completion response has no choices")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
+
+func (o *OpenAICompatible) Model() string {
+	return o.ModelName
+}
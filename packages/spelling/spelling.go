@@ -0,0 +1,235 @@
+// Package spelling corrects misspelled words in search queries against a
+// vocabulary built from the card collection.
+package spelling
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minWordLength keeps short words, where one edit changes the meaning
+// ("cat" vs "bat"), out of correction.
+const minWordLength = 4
+
+// Correction records one corrected word.
+type Correction struct {
+	Original  string `json:"original"`
+	Corrected string `json:"corrected"`
+}
+
+// maxIndexedEdits is the largest edit distance maxEdits allows, and so the
+// number of letters the delete index removes from each word.
+const maxIndexedEdits = 2
+
+// Vocabulary counts how often each lower-cased word occurs; frequent words
+// win ties between equally close candidates.
+type Vocabulary struct {
+	counts map[string]int
+	// deletes maps every string made by removing up to maxIndexedEdits
+	// letters from a word (the word itself included) to the words it came
+	// from. Two words within n edits share such a string with at most n
+	// letters removed from each, so closest only has to compare the words
+	// found under the query's own deletes instead of scanning every word.
+	deletes map[string][]string
+}
+
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{counts: map[string]int{}, deletes: map[string][]string{}}
+}
+
+// Add counts every word in text.
+func (v *Vocabulary) Add(text string) {
+	for _, word := range splitWords(text) {
+		lower := strings.ToLower(word.text)
+		v.counts[lower]++
+		if v.counts[lower] == 1 && utf8.RuneCountInString(lower) >= minWordLength {
+			for variant := range deletions(lower, maxIndexedEdits) {
+				v.deletes[variant] = append(v.deletes[variant], lower)
+			}
+		}
+	}
+}
+
+func (v *Vocabulary) Len() int {
+	return len(v.counts)
+}
+
+func (v *Vocabulary) Contains(word string) bool {
+	_, ok := v.counts[strings.ToLower(word)]
+	return ok
+}
+
+type word struct {
+	text       string
+	start, end int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || r == '\''
+}
+
+// splitWords returns runs of letters and apostrophes. Digits, symbols such
+// as {T} and punctuation separate words and are never corrected.
+func splitWords(text string) []word {
+	var words []word
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, word{text: text[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{text: text[start:], start: start, end: len(text)})
+	}
+	return words
+}
+
+// maxEdits allows one edit for short words and two from eight letters on.
+func maxEdits(length int) int {
+	if length >= 8 {
+		return 2
+	}
+	return 1
+}
+
+// deletions returns every string made by removing up to n letters from w,
+// including w itself.
+func deletions(w string, n int) map[string]bool {
+	variants := map[string]bool{w: true}
+	frontier := []string{w}
+	for ; n > 0; n-- {
+		var next []string
+		for _, s := range frontier {
+			runes := []rune(s)
+			if len(runes) <= 1 {
+				continue
+			}
+			for i := range runes {
+				variant := string(runes[:i]) + string(runes[i+1:])
+				if !variants[variant] {
+					variants[variant] = true
+					next = append(next, variant)
+				}
+			}
+		}
+		frontier = next
+	}
+	return variants
+}
+
+// closest returns the vocabulary word nearest to w within the allowed edit
+// distance, or "" if there is none.
+func (v *Vocabulary) closest(w string) string {
+	length := utf8.RuneCountInString(w)
+	limit := maxEdits(length)
+
+	best, bestDistance, bestCount := "", limit+1, 0
+	compared := map[string]bool{}
+	for variant := range deletions(w, limit) {
+		for _, candidate := range v.deletes[variant] {
+			if compared[candidate] {
+				continue
+			}
+			compared[candidate] = true
+			if abs(utf8.RuneCountInString(candidate)-length) > limit {
+				continue
+			}
+			count := v.counts[candidate]
+			distance := editDistance(w, candidate, limit)
+			if distance < bestDistance ||
+				(distance == bestDistance && (count > bestCount || (count == bestCount && candidate < best))) {
+				best, bestDistance, bestCount = candidate, distance, count
+			}
+		}
+	}
+	if bestDistance > limit {
+		return ""
+	}
+	return best
+}
+
+// Correct replaces each word of query that is not in the vocabulary with the
+// closest word that is, keeping the rest of the query as written.
+func (v *Vocabulary) Correct(query string) (string, []Correction) {
+	var out strings.Builder
+	corrections := []Correction{}
+	copied := 0
+	for _, w := range splitWords(query) {
+		if utf8.RuneCountInString(w.text) < minWordLength || v.Contains(w.text) {
+			continue
+		}
+		candidate := v.closest(strings.ToLower(w.text))
+		if candidate == "" {
+			continue
+		}
+		corrected := matchCase(w.text, candidate)
+		out.WriteString(query[copied:w.start])
+		out.WriteString(corrected)
+		copied = w.end
+		corrections = append(corrections, Correction{Original: w.text, Corrected: corrected})
+	}
+	out.WriteString(query[copied:])
+	return out.String(), corrections
+}
+
+// matchCase gives the correction the capitalisation of the original word,
+// so "Eldrazzi" becomes "Eldrazi" and "FLYNG" becomes "FLYING".
+func matchCase(original, corrected string) string {
+	if strings.ToUpper(original) == original {
+		return strings.ToUpper(corrected)
+	}
+	if first, _ := utf8.DecodeRuneInString(original); unicode.IsUpper(first) {
+		r, n := utf8.DecodeRuneInString(corrected)
+		return string(unicode.ToUpper(r)) + corrected[n:]
+	}
+	return corrected
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// editDistance is the optimal string alignment distance (Levenshtein plus
+// adjacent transpositions) between a and b. It stops early and returns
+// limit+1 once every alignment exceeds limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], prevPrev[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prevPrev, prev, current = prev, current, prevPrev
+	}
+	return prev[len(rb)]
+}
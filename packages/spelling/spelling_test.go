@@ -0,0 +1,83 @@
+package spelling
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testVocabulary() *Vocabulary {
+	v := NewVocabulary()
+	v.Add("Flying. When this creature enters, draw a card.")
+	v.Add("Eldrazi Spawn token with flying and trample")
+	v.Add("Destroy target creature. Its controller loses life.")
+	v.Add("Counter target spell. Scry 1.")
+	// "cheap" and "deck" describe cards without appearing on them
+	v.Add("cheap deck")
+	return v
+}
+
+func TestCorrect(t *testing.T) {
+	v := testVocabulary()
+
+	tests := []struct {
+		query       string
+		want        string
+		corrections []Correction
+	}{
+		{"flyng", "flying", []Correction{{Original: "flyng", Corrected: "flying"}}},
+		{"Eldrazzi", "Eldrazi", []Correction{{Original: "Eldrazzi", Corrected: "Eldrazi"}}},
+		{"FLYNG creature", "FLYING creature", []Correction{{Original: "FLYNG", Corrected: "FLYING"}}},
+		{"destory target creture", "destroy target creature", []Correction{
+			{Original: "destory", Corrected: "destroy"},
+			{Original: "creture", Corrected: "creature"},
+		}},
+		{"{T}: flyng 2/2", "{T}: flying 2/2", []Correction{{Original: "flyng", Corrected: "flying"}}},
+
+		// Words in the vocabulary, short words and words too far from any
+		// vocabulary word are left alone
+		{"flying", "flying", []Correction{}},
+		{"cheap deck", "cheap deck", []Correction{}},
+		{"cat bat", "cat bat", []Correction{}},
+		{"planeswalker", "planeswalker", []Correction{}},
+		{"zzzz", "zzzz", []Correction{}},
+		{"", "", []Correction{}},
+	}
+	for _, test := range tests {
+		got, corrections := v.Correct(test.query)
+		if got != test.want {
+			t.Errorf("Correct(%q) = %q, want %q", test.query, got, test.want)
+		}
+		if !reflect.DeepEqual(corrections, test.corrections) {
+			t.Errorf("Correct(%q) corrections = %+v, want %+v", test.query, corrections, test.corrections)
+		}
+	}
+}
+
+func TestCorrectPrefersFrequentWords(t *testing.T) {
+	v := NewVocabulary()
+	v.Add("tapped")
+	v.Add("tapper tapper tapper")
+	// "tappef" is one substitution from both
+	if got, _ := v.Correct("tappef"); got != "tapper" {
+		t.Errorf("Correct(tappef) = %q, want the more frequent tapper", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"flying", "flying", 0},
+		{"flyng", "flying", 1},
+		{"destory", "destroy", 1},
+		{"eldrazzi", "eldrazi", 1},
+		{"creture", "creature", 1},
+		{"abcd", "wxyz", 3},
+	}
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b, 2); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
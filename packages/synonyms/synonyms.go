@@ -0,0 +1,201 @@
+// Package synonyms rewrites Magic: The Gathering jargon in search queries
+// into the wording used in oracle text, e.g. "wrath" into "destroy all
+// creatures", so embeddings and BM25 both find the cards players mean.
+package synonyms
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+//go:embed default.toml
+var defaultDictionary []byte
+
+// Entry maps one or more terms to the oracle wording they stand for.
+type Entry struct {
+	Terms     []string `toml:"terms"`
+	Expansion string   `toml:"expansion"`
+	// Replace swaps the matched term for the expansion instead of adding the
+	// expansion after it
+	Replace bool `toml:"replace"`
+}
+
+type file struct {
+	Synonyms []Entry `toml:"synonym"`
+}
+
+// Expansion records one rewrite applied to a query.
+type Expansion struct {
+	// Term is the text matched in the query, as written
+	Term      string `json:"term"`
+	Expansion string `json:"expansion"`
+	// Replaced is set when the term was replaced rather than expanded
+	Replaced bool `json:"replaced"`
+}
+
+// Dictionary looks terms up by their lower-cased words.
+type Dictionary struct {
+	entries  map[string]Entry
+	maxWords int
+}
+
+// Default returns the built-in dictionary.
+func Default() *Dictionary {
+	d, err := Parse(defaultDictionary)
+	if err != nil {
+		panic(fmt.Sprintf("synonyms: invalid default dictionary: %v", err))
+	}
+	return d
+}
+
+// Parse reads a dictionary from TOML with one [[synonym]] table per entry.
+func Parse(data []byte) (*Dictionary, error) {
+	var parsed file
+	if err := toml.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+
+	d := &Dictionary{entries: map[string]Entry{}}
+	for i, entry := range parsed.Synonyms {
+		if strings.TrimSpace(entry.Expansion) == "" || len(entry.Terms) == 0 {
+			return nil, fmt.Errorf("synonym %d needs terms and an expansion", i+1)
+		}
+		d.add(entry)
+	}
+	return d, nil
+}
+
+// Load reads a dictionary file.
+func Load(path string) (*Dictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Merge adds other's entries to d, replacing entries for the same terms.
+func (d *Dictionary) Merge(other *Dictionary) {
+	for key, entry := range other.entries {
+		d.entries[key] = entry
+		d.maxWords = max(d.maxWords, len(strings.Fields(key)))
+	}
+}
+
+func (d *Dictionary) add(entry Entry) {
+	for _, term := range entry.Terms {
+		words := normalizedWords(term)
+		if len(words) == 0 {
+			continue
+		}
+		d.entries[strings.Join(words, " ")] = entry
+		d.maxWords = max(d.maxWords, len(words))
+	}
+}
+
+func (d *Dictionary) Len() int {
+	return len(d.entries)
+}
+
+// word is a run of letters, digits and apostrophes with its byte span in
+// the query.
+type word struct {
+	text       string
+	start, end int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
+
+func splitWords(query string) []word {
+	var words []word
+	start := -1
+	for i, r := range query {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, word{text: strings.ToLower(query[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{text: strings.ToLower(query[start:]), start: start, end: len(query)})
+	}
+	return words
+}
+
+func normalizedWords(term string) []string {
+	var words []string
+	for _, w := range splitWords(term) {
+		words = append(words, w.text)
+	}
+	return words
+}
+
+// Expand rewrites every dictionary term in query, matching whole words and
+// preferring the longest phrase at each position. Expansions already present
+// in the query are not added again.
+func (d *Dictionary) Expand(query string) (string, []Expansion) {
+	words := splitWords(query)
+	lowerQuery := strings.ToLower(query)
+
+	var out strings.Builder
+	applied := []Expansion{}
+	copied := 0
+	for i := 0; i < len(words); {
+		matched := 0
+		var entry Entry
+		for n := min(d.maxWords, len(words)-i); n > 0; n-- {
+			key := make([]string, 0, n)
+			for _, w := range words[i : i+n] {
+				key = append(key, w.text)
+			}
+			if e, ok := d.entries[strings.Join(key, " ")]; ok {
+				matched, entry = n, e
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+
+		start, end := words[i].start, words[i+matched-1].end
+		term := query[start:end]
+		i += matched
+		if !entry.Replace && strings.Contains(lowerQuery, strings.ToLower(entry.Expansion)) {
+			continue
+		}
+
+		out.WriteString(query[copied:start])
+		if entry.Replace {
+			out.WriteString(entry.Expansion)
+		} else {
+			out.WriteString(term + " " + entry.Expansion)
+		}
+		copied = end
+		applied = append(applied, Expansion{Term: term, Expansion: entry.Expansion, Replaced: entry.Replace})
+	}
+	out.WriteString(query[copied:])
+
+	return out.String(), applied
+}
+
+// Terms lists every term the dictionary matches, lower-cased.
+func (d *Dictionary) Terms() []string {
+	terms := make([]string, 0, len(d.entries))
+	for term := range d.entries {
+		terms = append(terms, term)
+	}
+	return terms
+}
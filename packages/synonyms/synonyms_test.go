@@ -0,0 +1,140 @@
+package synonyms
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testDictionary = `
+[[synonym]]
+terms = ["etb", "enters the battlefield"]
+expansion = "enters the battlefield"
+
+[[synonym]]
+terms = ["wrath", "board wipe"]
+expansion = "destroy all creatures"
+
+[[synonym]]
+terms = ["board"]
+expansion = "battlefield"
+
+[[synonym]]
+terms = ["dork"]
+expansion = "creature that adds mana"
+replace = true
+`
+
+func TestExpand(t *testing.T) {
+	d, err := Parse([]byte(testDictionary))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		want       string
+		expansions []Expansion
+	}{
+		{
+			name:  "single word",
+			query: "cheap wrath",
+			want:  "cheap wrath destroy all creatures",
+			expansions: []Expansion{
+				{Term: "wrath", Expansion: "destroy all creatures"},
+			},
+		},
+		{
+			name:  "longest phrase wins",
+			query: "Board Wipe for control",
+			want:  "Board Wipe destroy all creatures for control",
+			expansions: []Expansion{
+				{Term: "Board Wipe", Expansion: "destroy all creatures"},
+			},
+		},
+		{
+			name:  "replace",
+			query: "green dork",
+			want:  "green creature that adds mana",
+			expansions: []Expansion{
+				{Term: "dork", Expansion: "creature that adds mana", Replaced: true},
+			},
+		},
+		{
+			name:       "whole words only",
+			query:      "wrathful boards",
+			want:       "wrathful boards",
+			expansions: []Expansion{},
+		},
+		{
+			name:       "expansion already in the query",
+			query:      "etb creature that enters the battlefield",
+			want:       "etb creature that enters the battlefield",
+			expansions: []Expansion{},
+		},
+		{
+			name:  "several terms keep the text between them",
+			query: "etb, then wrath!",
+			want:  "etb enters the battlefield, then wrath destroy all creatures!",
+			expansions: []Expansion{
+				{Term: "etb", Expansion: "enters the battlefield"},
+				{Term: "wrath", Expansion: "destroy all creatures"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, expansions := d.Expand(test.query)
+			if got != test.want {
+				t.Errorf("Expand(%q) = %q, want %q", test.query, got, test.want)
+			}
+			if !reflect.DeepEqual(expansions, test.expansions) {
+				t.Errorf("Expand(%q) expansions = %+v, want %+v", test.query, expansions, test.expansions)
+			}
+		})
+	}
+}
+
+func TestParseRejectsIncompleteEntries(t *testing.T) {
+	for _, data := range []string{
+		"[[synonym]]\nterms = [\"wrath\"]\n",
+		"[[synonym]]\nexpansion = \"destroy all creatures\"\n",
+		"[[synonym]\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestMergeReplacesTerms(t *testing.T) {
+	d, err := Parse([]byte(testDictionary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := Parse([]byte("[[synonym]]\nterms = [\"wrath\", \"mass bounce effect\"]\nexpansion = \"return all creatures\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Merge(custom)
+
+	if got, _ := d.Expand("wrath"); got != "wrath return all creatures" {
+		t.Errorf("merged wrath = %q", got)
+	}
+	if got, _ := d.Expand("a mass bounce effect"); got != "a mass bounce effect return all creatures" {
+		t.Errorf("merged three word term = %q", got)
+	}
+	if got, _ := d.Expand("board wipe"); got != "board wipe destroy all creatures" {
+		t.Errorf("unmerged term = %q", got)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	d := Default()
+	if d.Len() == 0 {
+		t.Fatal("default dictionary is empty")
+	}
+	if _, expansions := d.Expand("etb"); len(expansions) != 1 {
+		t.Errorf("default dictionary does not expand etb: %+v", expansions)
+	}
+}
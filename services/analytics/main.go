@@ -0,0 +1,41 @@
+// Command analytics prints a report of the server's search analytics log:
+// the most frequent queries, queries that returned nothing, and queries whose
+// best match is poor.
+//
+//	go run ./services/analytics -dir services/server/analytics -since 24h
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"mtguru/packages/analytics"
+)
+
+func main() {
+	dir := flag.String("dir", "analytics", "directory holding the searches*.jsonl logs")
+	since := flag.Duration("since", 7*24*time.Hour, "how far back to report")
+	limit := flag.Int("limit", 20, "entries per list")
+	poor := flag.Float64("poor-distance", 0.6, "best-hit distance above which a query is a poor match")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	start := time.Now().UTC().Add(-*since)
+	events, err := analytics.ReadEvents(*dir, start)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reading analytics:", err)
+		os.Exit(1)
+	}
+
+	report := analytics.BuildReport(events, start, *poor, *limit)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		return
+	}
+	analytics.WriteText(os.Stdout, report)
+}
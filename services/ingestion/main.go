@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 
@@ -46,8 +49,18 @@ func createClient(conf config.EnvironmentConfig) *weaviate.Client {
 }
 
 func main() {
-	createIndex(client)
-	populateIndex(client)
+	// Cancel in-flight batches on Ctrl+C / SIGTERM instead of leaving them running
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	createIndex(ctx, client)
+	populateIndex(ctx, client)
+	createRulingsIndex(ctx, client)
+	populateRulingsIndex(ctx, client)
+	createSetsIndex(ctx, client)
+	populateSetsIndex(ctx, client)
+	createPrintingsIndex(ctx, client)
+	populatePrintingsIndex(ctx, client)
 	// searchDatabase(client)
-	// updateCollection(client)
+	// updateCollection(ctx, client)
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// printingsClass holds one object per Scryfall printing, from the default
+// cards bulk file. The Mtguru class keeps one printing per card for search;
+// set and artist pages list every printing from this class instead. Nothing
+// is vectorized.
+const printingsClass = "MtguruPrinting"
+
+const printingsFile = "data/default-cards-20250429210412.json"
+
+func createPrintingsIndex(ctx context.Context, client *weaviate.Client) {
+	properties := []*models.Property{}
+	for _, name := range []string{"scryfall_id", "oracle_id", "name", "layout", "released_at", "scryfall_uri", "mana_cost", "type_line", "oracle_text", "set", "set_name", "set_type", "rarity", "collector_number", "artist"} {
+		properties = append(properties, &models.Property{Name: name, DataType: []string{"string"}})
+	}
+	for _, name := range []string{"colors", "color_identity", "artist_ids"} {
+		properties = append(properties, &models.Property{Name: name, DataType: []string{"string[]"}})
+	}
+	properties = append(properties,
+		&models.Property{Name: "mtgo_id", DataType: []string{"int"}},
+		&models.Property{Name: "cmc", DataType: []string{"number"}},
+		&models.Property{Name: "digital", DataType: []string{"boolean"}},
+		&models.Property{Name: "card_faces", DataType: []string{"object[]"}, NestedProperties: faceNestedProperties()},
+		&models.Property{Name: "image_uris", DataType: []string{"object"}, NestedProperties: imageURIsNestedProperties()},
+	)
+
+	classObj := &models.Class{
+		Class:      printingsClass,
+		Vectorizer: "none",
+		Properties: properties,
+	}
+
+	slog.Info("Creating collection '" + printingsClass + "'...")
+
+	err := client.Schema().ClassCreator().WithClass(classObj).Do(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("Collection '" + printingsClass + "' created")
+}
+
+func printingObject(card Card) *models.Object {
+	card = withFaces(card)
+	return &models.Object{
+		Class: printingsClass,
+		ID:    strfmt.UUID(card.ScryfallID),
+		Properties: map[string]any{
+			"scryfall_id":      card.ScryfallID,
+			"oracle_id":        card.OracleID,
+			"name":             card.Name,
+			"layout":           card.Layout,
+			"released_at":      card.ReleasedAt,
+			"scryfall_uri":     card.ScryfallURI,
+			"mana_cost":        card.ManaCost,
+			"type_line":        card.TypeLine,
+			"oracle_text":      card.OracleText,
+			"set":              card.Set,
+			"set_name":         card.SetName,
+			"set_type":         card.SetType,
+			"rarity":           card.Rarity,
+			"collector_number": card.CollectorNumber,
+			"artist":           card.Artist,
+			"colors":           card.Colors,
+			"color_identity":   card.ColorIdentity,
+			"artist_ids":       card.ArtistIDs,
+			"mtgo_id":          card.MtgoID,
+			"cmc":              card.Cmc,
+			"digital":          card.Digital,
+			"card_faces":       faceProperties(card.CardFaces),
+			"image_uris":       card.ImageURIs,
+		},
+	}
+}
+
+// populatePrintingsIndex streams the default cards file, which is several
+// times the size of the oracle cards file, decoding and writing one batch at
+// a time instead of loading every printing into memory.
+func populatePrintingsIndex(ctx context.Context, client *weaviate.Client) {
+	jsonFile, err := os.Open(printingsFile)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	defer jsonFile.Close()
+
+	decoder := json.NewDecoder(jsonFile)
+	if _, err := decoder.Token(); err != nil {
+		slog.Error("Could not read printings", "error", err.Error())
+		return
+	}
+
+	batchSize := 100
+	objects := make([]*models.Object, 0, batchSize)
+	total := 0
+	for decoder.More() {
+		var card Card
+		if err := decoder.Decode(&card); err != nil {
+			slog.Error("Could not read printing", "index", total, "error", err.Error())
+			return
+		}
+		objects = append(objects, printingObject(card))
+		total++
+		if len(objects) < batchSize && decoder.More() {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			slog.Warn("Printings ingestion cancelled", "next_index", total-len(objects), "error", ctx.Err())
+			return
+		}
+
+		slog.Info(fmt.Sprintf("Batching printings from index %d to %d\n", total-len(objects), total))
+		batchRes, err := client.Batch().ObjectsBatcher().WithObjects(objects...).Do(ctx)
+
+		if err != nil {
+			fmt.Println("Batch operation failed:", err.Error())
+			return
+		}
+
+		for _, res := range batchRes {
+			if res.Result.Errors != nil {
+				for _, batchError := range res.Result.Errors.Error {
+					fmt.Printf("Batch error: %+v\n", batchError)
+				}
+			}
+		}
+		objects = objects[:0]
+	}
+
+	slog.Info("Number of total printings", "count", total)
+}
@@ -8,7 +8,10 @@ import (
 	"log/slog"
 	"mtguru/packages/custom_logger"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/go-openapi/strfmt"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
 	"github.com/weaviate/weaviate/entities/models"
@@ -18,6 +21,25 @@ func init() {
 	custom_logger.CreateLogger()
 }
 
+// CardFace is one face of a transform, modal double-faced, split, adventure
+// or flip card. Those cards leave some top-level fields empty (oracle_text,
+// and image_uris for double-faced layouts) and carry them per face instead.
+type CardFace struct {
+	Object     string            `json:"object"`
+	Name       string            `json:"name"`
+	ManaCost   string            `json:"mana_cost"`
+	TypeLine   string            `json:"type_line"`
+	OracleText string            `json:"oracle_text"`
+	Colors     []string          `json:"colors"`
+	Power      string            `json:"power"`
+	Toughness  string            `json:"toughness"`
+	Defence    string            `json:"defense"`
+	Loyalty    string            `json:"loyalty"`
+	FlavorText string            `json:"flavor_text"`
+	Artist     string            `json:"artist"`
+	ImageURIs  map[string]string `json:"image_uris"`
+}
+
 type Card struct {
 	Object        string            `json:"object"`
 	ScryfallID    string            `json:"id"`
@@ -26,6 +48,8 @@ type Card struct {
 	MtgoID        int               `json:"mtgo_id"`
 	TcgplayerID   int               `json:"tcgplayer_id"`
 	Name          string            `json:"name"`
+	Layout        string            `json:"layout"`
+	CardFaces     []CardFace        `json:"card_faces"`
 	ReleasedAt    string            `json:"released_at"`
 	ScryfallURI   string            `json:"scryfall_uri"`
 	ImageURIs     map[string]string `json:"image_uris"`
@@ -43,23 +67,26 @@ type Card struct {
 	ColorIdentity []string          `json:"color_identity"`
 	Keywords      []string          `json:"keywords"`
 	ProducedMana  []string          `json:"produced_mana"`
-	// Legalities    map[string]string  `json:"legalities"`
-	Games       []string `json:"games"`
-	Reserved    bool     `json:"reserved"`
-	GameChanger bool     `json:"game_changer"`
-	Finishes    []string `json:"finishes"`
-	SetID       string   `json:"set_id"`
-	SetName     string   `json:"set_name"`
-	SetType     string   `json:"set_type"`
-	RulingsURI  string   `json:"rulings_uri"`
-	Digital     bool     `json:"digital"`
-	Rarity      string   `json:"rarity"`
-	FlavorText  string   `json:"flavor_text"`
-	CardBackID  string   `json:"card_back_id"`
-	Artist      string   `json:"artist"`
-	ArtistIDs   []string `json:"artist_ids"`
-	BorderColor string   `json:"border_color"`
-	Booster     bool     `json:"booster"`
+	Legalities    map[string]string `json:"legalities"`
+	Games         []string          `json:"games"`
+	Reserved      bool              `json:"reserved"`
+	GameChanger   bool              `json:"game_changer"`
+	Finishes      []string          `json:"finishes"`
+	SetID         string            `json:"set_id"`
+	Set           string            `json:"set"`
+	SetName       string            `json:"set_name"`
+	SetType       string            `json:"set_type"`
+	RulingsURI    string            `json:"rulings_uri"`
+	Digital       bool              `json:"digital"`
+	Rarity        string            `json:"rarity"`
+	// CollectorNumber is a string because Scryfall uses values like "123a" and "★"
+	CollectorNumber string   `json:"collector_number"`
+	FlavorText      string   `json:"flavor_text"`
+	CardBackID      string   `json:"card_back_id"`
+	Artist          string   `json:"artist"`
+	ArtistIDs       []string `json:"artist_ids"`
+	BorderColor     string   `json:"border_color"`
+	Booster         bool     `json:"booster"`
 	// Prices        map[string]float64 `json:"prices"`
 	// RelatedURIs   map[string]string  `json:"related_uris"`
 	// PurchaseURIs  map[string]string  `json:"purchase_uris"`
@@ -103,7 +130,7 @@ func parseCardsFromFile() []Card {
 	return cards
 }
 
-func updateCollection(client *weaviate.Client) {
+func updateCollection(ctx context.Context, client *weaviate.Client) {
 	// var cards []Card = parseCardsFromFile()
 
 	vabatchSize := 20
@@ -125,9 +152,9 @@ func updateCollection(client *weaviate.Client) {
 			WithLimit(batchSize)
 
 		if cursor != "" {
-			return get.WithAfter(cursor).Do(context.Background())
+			return get.WithAfter(cursor).Do(ctx)
 		}
-		return get.Do(context.Background())
+		return get.Do(ctx)
 	}
 
 	response, err := getBatchWithCursor(client, className, classProperties, vabatchSize, "")
@@ -138,14 +165,96 @@ func updateCollection(client *weaviate.Client) {
 	slog.Info("Batch fetched successfully", "response", response)
 }
 
-func populateIndex(client *weaviate.Client) {
+// legalFormats lists the formats a card may be played in. Restricted cards
+// are legal (as a single copy), banned and not_legal ones are not.
+func legalFormats(legalities map[string]string) []string {
+	formats := []string{}
+	for format, status := range legalities {
+		if status == "legal" || status == "restricted" {
+			formats = append(formats, format)
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// combinedFaces joins a per-face field with " // " as Scryfall does for
+// names and type lines, skipping empty faces.
+func combinedFaces(faces []CardFace, field func(CardFace) string) string {
+	values := []string{}
+	for _, face := range faces {
+		if value := field(face); value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, " // ")
+}
+
+// withFaces fills the top-level fields that Scryfall leaves empty on
+// multi-faced cards, so every face's text is vectorized and searchable and
+// the front face's image is shown.
+func withFaces(card Card) Card {
+	if len(card.CardFaces) == 0 {
+		return card
+	}
+	if card.OracleText == "" {
+		card.OracleText = combinedFaces(card.CardFaces, func(face CardFace) string { return face.OracleText })
+	}
+	if card.ManaCost == "" {
+		card.ManaCost = combinedFaces(card.CardFaces, func(face CardFace) string { return face.ManaCost })
+	}
+	if card.FlavorText == "" {
+		card.FlavorText = combinedFaces(card.CardFaces, func(face CardFace) string { return face.FlavorText })
+	}
+	if len(card.ImageURIs) == 0 {
+		card.ImageURIs = card.CardFaces[0].ImageURIs
+	}
+	return card
+}
+
+// exactNames is what decklist lookups match a card by: its lower-cased full
+// name and, for multi-faced cards, its front face's name.
+func exactNames(card Card) []string {
+	names := []string{strings.ToLower(card.Name)}
+	if front, _, ok := strings.Cut(card.Name, " // "); ok {
+		names = append(names, strings.ToLower(strings.TrimSpace(front)))
+	}
+	return names
+}
+
+func faceProperties(faces []CardFace) []map[string]any {
+	properties := make([]map[string]any, 0, len(faces))
+	for _, face := range faces {
+		properties = append(properties, map[string]any{
+			"name":        face.Name,
+			"mana_cost":   face.ManaCost,
+			"type_line":   face.TypeLine,
+			"oracle_text": face.OracleText,
+			"colors":      face.Colors,
+			"power":       face.Power,
+			"toughness":   face.Toughness,
+			"defense":     face.Defence,
+			"loyalty":     face.Loyalty,
+			"flavor_text": face.FlavorText,
+			"artist":      face.Artist,
+			"image_uris":  face.ImageURIs,
+		})
+	}
+	return properties
+}
+
+func populateIndex(ctx context.Context, client *weaviate.Client) {
 	var cards []Card = parseCardsFromFile()
 
 	// populate index with data
 	objects := make([]*models.Object, len(cards))
 	for i := range cards {
+		cards[i] = withFaces(cards[i])
 		objects[i] = &models.Object{
 			Class: "mtguru",
+			// Scryfall ids are UUIDs, so using them as object ids keeps card
+			// ids, favorites and saved search results stable across re-ingests
+			ID: strfmt.UUID(cards[i].ScryfallID),
 			Properties: map[string]any{
 				"object":         cards[i].Object,
 				"scryfall_id":    cards[i].ScryfallID,
@@ -154,6 +263,9 @@ func populateIndex(client *weaviate.Client) {
 				"mtgo_id":        cards[i].MtgoID,
 				"tcgplayer_id":   cards[i].TcgplayerID,
 				"name":           cards[i].Name,
+				"name_exact":     exactNames(cards[i]),
+				"layout":         cards[i].Layout,
+				"card_faces":     faceProperties(cards[i].CardFaces),
 				"released_at":    cards[i].ReleasedAt,
 				"scryfall_uri":   cards[i].ScryfallURI,
 				"image_uris":     cards[i].ImageURIs,
@@ -172,22 +284,25 @@ func populateIndex(client *weaviate.Client) {
 				"keywords":       cards[i].Keywords,
 				"produced_mana":  cards[i].ProducedMana,
 				// "legalities":     cards[i].Legalities,
-				"games":        cards[i].Games,
-				"reserved":     cards[i].Reserved,
-				"game_changer": cards[i].GameChanger,
-				"finishes":     cards[i].Finishes,
-				"set_id":       cards[i].SetID,
-				"set_name":     cards[i].SetName,
-				"set_type":     cards[i].SetType,
-				"rulings_uri":  cards[i].RulingsURI,
-				"digital":      cards[i].Digital,
-				"rarity":       cards[i].Rarity,
-				"flavor_text":  cards[i].FlavorText,
-				"card_back_id": cards[i].CardBackID,
-				"artist":       cards[i].Artist,
-				"artist_ids":   cards[i].ArtistIDs,
-				"border_color": cards[i].BorderColor,
-				"booster":      cards[i].Booster,
+				"legal_formats":    legalFormats(cards[i].Legalities),
+				"games":            cards[i].Games,
+				"reserved":         cards[i].Reserved,
+				"game_changer":     cards[i].GameChanger,
+				"finishes":         cards[i].Finishes,
+				"set_id":           cards[i].SetID,
+				"set":              cards[i].Set,
+				"set_name":         cards[i].SetName,
+				"set_type":         cards[i].SetType,
+				"rulings_uri":      cards[i].RulingsURI,
+				"digital":          cards[i].Digital,
+				"rarity":           cards[i].Rarity,
+				"collector_number": cards[i].CollectorNumber,
+				"flavor_text":      cards[i].FlavorText,
+				"card_back_id":     cards[i].CardBackID,
+				"artist":           cards[i].Artist,
+				"artist_ids":       cards[i].ArtistIDs,
+				"border_color":     cards[i].BorderColor,
+				"booster":          cards[i].Booster,
 				// "prices":         cards[i].Prices,
 				// "related_uris":   cards[i].RelatedURIs,
 				// "purchase_uris":  cards[i].PurchaseURIs,
@@ -203,8 +318,13 @@ func populateIndex(client *weaviate.Client) {
 			end = len(objects)
 		}
 
+		if ctx.Err() != nil {
+			slog.Warn("Ingestion cancelled", "next_index", i, "total", len(objects), "error", ctx.Err())
+			return
+		}
+
 		slog.Info(fmt.Sprintf("Batching objects from index %d to %d\n", i, end))
-		batchRes, err := client.Batch().ObjectsBatcher().WithObjects(objects[i:end]...).Do(context.Background())
+		batchRes, err := client.Batch().ObjectsBatcher().WithObjects(objects[i:end]...).Do(ctx)
 
 		if err != nil {
 			fmt.Println("Batch operation failed:", err.Error())
@@ -221,7 +341,26 @@ func populateIndex(client *weaviate.Client) {
 	}
 }
 
-func createIndex(client *weaviate.Client) {
+func imageURIsNestedProperties() []*models.NestedProperty {
+	properties := []*models.NestedProperty{}
+	for _, size := range []string{"small", "normal", "large", "png", "art_crop", "border_crop"} {
+		properties = append(properties, &models.NestedProperty{Name: size, DataType: []string{"text"}})
+	}
+	return properties
+}
+
+func faceNestedProperties() []*models.NestedProperty {
+	properties := []*models.NestedProperty{}
+	for _, name := range []string{"name", "mana_cost", "type_line", "oracle_text", "power", "toughness", "defense", "loyalty", "flavor_text", "artist"} {
+		properties = append(properties, &models.NestedProperty{Name: name, DataType: []string{"text"}})
+	}
+	return append(properties,
+		&models.NestedProperty{Name: "colors", DataType: []string{"text[]"}},
+		&models.NestedProperty{Name: "image_uris", DataType: []string{"object"}, NestedProperties: imageURIsNestedProperties()},
+	)
+}
+
+func createIndex(ctx context.Context, client *weaviate.Client) {
 	// define the collection
 	classObj := &models.Class{
 		Class:      "mtguru",
@@ -234,23 +373,27 @@ func createIndex(client *weaviate.Client) {
 			},
 			"generative-cohere": map[string]interface{}{},
 			"vectorizePropertyName": map[string]bool{
-				"scryfall_id":    false,
-				"oracle_id":      false,
-				"multiverse_ids": false,
-				"mtgo_id":        false,
-				"tcgplayer_id":   false,
-				"scryfall_uri":   false,
-				"image_uris":     false,
-				"games":          false,
-				"reserved":       false,
-				"game_changer":   false,
-				"finishes":       false,
-				"set_id":         false,
-				"rulings_uri":    false,
-				"digital":        false,
-				"card_back_id":   false,
-				"artist_ids":     false,
-				"border_color":   false,
+				"scryfall_id":      false,
+				"name_exact":       false,
+				"oracle_id":        false,
+				"multiverse_ids":   false,
+				"mtgo_id":          false,
+				"tcgplayer_id":     false,
+				"scryfall_uri":     false,
+				"layout":           false,
+				"image_uris":       false,
+				"games":            false,
+				"reserved":         false,
+				"game_changer":     false,
+				"finishes":         false,
+				"set_id":           false,
+				"set":              false,
+				"collector_number": false,
+				"rulings_uri":      false,
+				"digital":          false,
+				"card_back_id":     false,
+				"artist_ids":       false,
+				"border_color":     false,
 			},
 		},
 		Properties: []*models.Property{
@@ -282,6 +425,27 @@ func createIndex(client *weaviate.Client) {
 				Name:     "name",
 				DataType: []string{"string"},
 			},
+			{
+				// "name" is tokenized into words, so "Island" also matches
+				// "Tropical Island"; field tokenization matches the whole name
+				Name:         "name_exact",
+				DataType:     []string{"text[]"},
+				Tokenization: "field",
+				ModuleConfig: map[string]interface{}{
+					"text2vec-openai": map[string]interface{}{"skip": true},
+				},
+			},
+			{
+				Name:     "layout",
+				DataType: []string{"string"},
+			},
+			{
+				// Per-face fields of multi-faced cards; the top-level text fields
+				// hold every face joined with " // "
+				Name:             "card_faces",
+				DataType:         []string{"object[]"},
+				NestedProperties: faceNestedProperties(),
+			},
 			{
 				Name:     "released_at",
 				DataType: []string{"string"},
@@ -380,6 +544,11 @@ func createIndex(client *weaviate.Client) {
 			// 	Name:     "legalities",
 			// 	DataType: []string{"object"},
 			// },
+			{
+				// Flattened from legalities so it can be filtered on, e.g. ContainsAny ["modern"]
+				Name:     "legal_formats",
+				DataType: []string{"string[]"},
+			},
 			{
 				Name:     "games",
 				DataType: []string{"string[]"},
@@ -400,6 +569,10 @@ func createIndex(client *weaviate.Client) {
 				Name:     "set_id",
 				DataType: []string{"string"},
 			},
+			{
+				Name:     "set",
+				DataType: []string{"string"},
+			},
 			{
 				Name:     "set_name",
 				DataType: []string{"string"},
@@ -420,6 +593,10 @@ func createIndex(client *weaviate.Client) {
 				Name:     "rarity",
 				DataType: []string{"string"},
 			},
+			{
+				Name:     "collector_number",
+				DataType: []string{"string"},
+			},
 			{
 				Name:     "flavor_text",
 				DataType: []string{"string"},
@@ -461,7 +638,7 @@ func createIndex(client *weaviate.Client) {
 
 	slog.Info("Creating collection 'mtguru'...")
 
-	err := client.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
+	err := client.Schema().ClassCreator().WithClass(classObj).Do(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// rulingsClass holds one object per Scryfall ruling. Rulings are shared by
+// every printing of a card, so they are keyed by oracle_id rather than
+// linked to a card object.
+const rulingsClass = "MtguruRuling"
+
+type Ruling struct {
+	Object      string `json:"object"`
+	OracleID    string `json:"oracle_id"`
+	Source      string `json:"source"`
+	PublishedAt string `json:"published_at"`
+	Comment     string `json:"comment"`
+}
+
+func parseRulingsFromFile() []Ruling {
+
+	jsonFile, err := os.Open("data/rulings-20250429210030.json")
+	if err != nil {
+		slog.Error(err.Error())
+	}
+
+	defer jsonFile.Close()
+
+	byteValue, _ := io.ReadAll(jsonFile)
+	var rulings []Ruling
+	json.Unmarshal(byteValue, &rulings)
+
+	slog.Info("Number of total rulings", "count", len(rulings))
+
+	return rulings
+}
+
+// skipVectorization keeps a property out of the text2vec-openai input.
+var skipVectorization = map[string]any{
+	"text2vec-openai": map[string]any{
+		"skip": true,
+	},
+}
+
+func createRulingsIndex(ctx context.Context, client *weaviate.Client) {
+	// Only the comment is embedded so rules questions match the ruling text
+	classObj := &models.Class{
+		Class:      rulingsClass,
+		Vectorizer: "text2vec-openai",
+		ModuleConfig: map[string]any{
+			"text2vec-openai": map[string]any{
+				"model":              "text-embedding-3-large",
+				"dimensions":         1024,
+				"vectorizeClassName": false,
+			},
+		},
+		Properties: []*models.Property{
+			{
+				Name:         "oracle_id",
+				DataType:     []string{"string"},
+				ModuleConfig: skipVectorization,
+			},
+			{
+				Name:         "source",
+				DataType:     []string{"string"},
+				ModuleConfig: skipVectorization,
+			},
+			{
+				Name:         "published_at",
+				DataType:     []string{"string"},
+				ModuleConfig: skipVectorization,
+			},
+			{
+				Name:     "comment",
+				DataType: []string{"text"},
+			},
+		},
+	}
+
+	slog.Info("Creating collection '" + rulingsClass + "'...")
+
+	err := client.Schema().ClassCreator().WithClass(classObj).Do(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("Collection '" + rulingsClass + "' created")
+}
+
+func populateRulingsIndex(ctx context.Context, client *weaviate.Client) {
+	var rulings []Ruling = parseRulingsFromFile()
+
+	objects := make([]*models.Object, len(rulings))
+	for i := range rulings {
+		objects[i] = &models.Object{
+			Class: rulingsClass,
+			Properties: map[string]any{
+				"oracle_id":    rulings[i].OracleID,
+				"source":       rulings[i].Source,
+				"published_at": rulings[i].PublishedAt,
+				"comment":      rulings[i].Comment,
+			},
+		}
+	}
+
+	batchSize := 100
+	for i := 0; i < len(objects); i += batchSize {
+		end := min(i+batchSize, len(objects))
+
+		if ctx.Err() != nil {
+			slog.Warn("Rulings ingestion cancelled", "next_index", i, "total", len(objects), "error", ctx.Err())
+			return
+		}
+
+		slog.Info(fmt.Sprintf("Batching rulings from index %d to %d\n", i, end))
+		batchRes, err := client.Batch().ObjectsBatcher().WithObjects(objects[i:end]...).Do(ctx)
+
+		if err != nil {
+			fmt.Println("Batch operation failed:", err.Error())
+			return
+		}
+
+		for _, res := range batchRes {
+			if res.Result.Errors != nil {
+				for _, batchError := range res.Result.Errors.Error {
+					fmt.Printf("Batch error: %+v\n", batchError)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// setsClass holds one object per Scryfall set. It only backs set browsing,
+// so nothing is vectorized.
+const setsClass = "MtguruSet"
+
+type Set struct {
+	Object        string `json:"object"`
+	ID            string `json:"id"`
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	SetType       string `json:"set_type"`
+	ReleasedAt    string `json:"released_at"`
+	BlockCode     string `json:"block_code"`
+	Block         string `json:"block"`
+	ParentSetCode string `json:"parent_set_code"`
+	CardCount     int    `json:"card_count"`
+	Digital       bool   `json:"digital"`
+	IconSvgURI    string `json:"icon_svg_uri"`
+	ScryfallURI   string `json:"scryfall_uri"`
+}
+
+// setList is the body of https://api.scryfall.com/sets, saved to a file.
+type setList struct {
+	Object string `json:"object"`
+	Data   []Set  `json:"data"`
+}
+
+func parseSetsFromFile() []Set {
+
+	jsonFile, err := os.Open("data/sets-20250429.json")
+	if err != nil {
+		slog.Error(err.Error())
+	}
+
+	defer jsonFile.Close()
+
+	byteValue, _ := io.ReadAll(jsonFile)
+	var sets setList
+	json.Unmarshal(byteValue, &sets)
+
+	slog.Info("Number of total sets", "count", len(sets.Data))
+
+	return sets.Data
+}
+
+func createSetsIndex(ctx context.Context, client *weaviate.Client) {
+	classObj := &models.Class{
+		Class:      setsClass,
+		Vectorizer: "none",
+		Properties: []*models.Property{
+			{
+				Name:     "scryfall_id",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "code",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "name",
+				DataType: []string{"text"},
+			},
+			{
+				Name:     "set_type",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "released_at",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "block_code",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "block",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "parent_set_code",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "card_count",
+				DataType: []string{"int"},
+			},
+			{
+				Name:     "digital",
+				DataType: []string{"boolean"},
+			},
+			{
+				Name:     "icon_svg_uri",
+				DataType: []string{"string"},
+			},
+			{
+				Name:     "scryfall_uri",
+				DataType: []string{"string"},
+			},
+		},
+	}
+
+	slog.Info("Creating collection '" + setsClass + "'...")
+
+	err := client.Schema().ClassCreator().WithClass(classObj).Do(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("Collection '" + setsClass + "' created")
+}
+
+func populateSetsIndex(ctx context.Context, client *weaviate.Client) {
+	var sets []Set = parseSetsFromFile()
+
+	objects := make([]*models.Object, len(sets))
+	for i := range sets {
+		objects[i] = &models.Object{
+			Class: setsClass,
+			Properties: map[string]any{
+				"scryfall_id":     sets[i].ID,
+				"code":            sets[i].Code,
+				"name":            sets[i].Name,
+				"set_type":        sets[i].SetType,
+				"released_at":     sets[i].ReleasedAt,
+				"block_code":      sets[i].BlockCode,
+				"block":           sets[i].Block,
+				"parent_set_code": sets[i].ParentSetCode,
+				"card_count":      sets[i].CardCount,
+				"digital":         sets[i].Digital,
+				"icon_svg_uri":    sets[i].IconSvgURI,
+				"scryfall_uri":    sets[i].ScryfallURI,
+			},
+		}
+	}
+
+	batchSize := 100
+	for i := 0; i < len(objects); i += batchSize {
+		end := min(i+batchSize, len(objects))
+
+		if ctx.Err() != nil {
+			slog.Warn("Sets ingestion cancelled", "next_index", i, "total", len(objects), "error", ctx.Err())
+			return
+		}
+
+		slog.Info(fmt.Sprintf("Batching sets from index %d to %d\n", i, end))
+		batchRes, err := client.Batch().ObjectsBatcher().WithObjects(objects[i:end]...).Do(ctx)
+
+		if err != nil {
+			fmt.Println("Batch operation failed:", err.Error())
+			return
+		}
+
+		for _, res := range batchRes {
+			if res.Result.Errors != nil {
+				for _, batchError := range res.Result.Errors.Error {
+					fmt.Printf("Batch error: %+v\n", batchError)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"mtguru/packages/analytics"
+	"mtguru/packages/config"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAnalyticsDir = "analytics"
+	defaultPoorDistance = 0.6
+	defaultReportWindow = 7 * 24 * time.Hour
+	defaultReportLimit  = 20
+	maxReportLimit      = 200
+	searchSourceSearch  = "search"
+	searchSourceStream  = "stream"
+	searchSourceBatch   = "batch"
+)
+
+// searchLog is nil when analytics are disabled.
+var searchLog *analytics.Logger
+
+func openSearchLog(conf config.AnalyticsConfig) (*analytics.Logger, error) {
+	if conf.DISABLED {
+		return nil, nil
+	}
+	dir := conf.DIR
+	if dir == "" {
+		dir = defaultAnalyticsDir
+	}
+	return analytics.NewLogger(dir, int64(conf.MAX_FILE_MB)<<20, conf.MAX_FILES)
+}
+
+func analyticsDir() string {
+	if activeConfig.ANALYTICS.DIR != "" {
+		return activeConfig.ANALYTICS.DIR
+	}
+	return defaultAnalyticsDir
+}
+
+func poorDistance() float64 {
+	if activeConfig.ANALYTICS.POOR_DISTANCE > 0 {
+		return activeConfig.ANALYTICS.POOR_DISTANCE
+	}
+	return defaultPoorDistance
+}
+
+// recordSearch appends one search to the analytics log. errCode is the API
+// error code if the search failed. Failures to record are only logged.
+func recordSearch(ctx context.Context, source string, request MTGuruSearchRequest, start time.Time, cards []map[string]any, errCode string) {
+	if searchLog == nil {
+		return
+	}
+
+	mode := request.Mode
+	if mode == "" {
+		mode = searchModeSemantic
+	}
+	event := analytics.Event{
+		Time:      start.UTC(),
+		Query:     analytics.NormalizeQuery(request.Query),
+		Mode:      mode,
+		Source:    source,
+		Results:   len(cards),
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Error:     errCode,
+	}
+
+	filters := map[string]string{}
+	if request.Filters.Color != "" {
+		filters["colors"] = request.Filters.Color
+	}
+	if request.Filters.Rarity != "" {
+		filters["rarity"] = request.Filters.Rarity
+	}
+	if request.Filters.SetType != "" {
+		filters["set_type"] = request.Filters.SetType
+	}
+	if request.Filters.Set != "" {
+		filters["set"] = request.Filters.Set
+	}
+	if request.Filters.Artist != "" {
+		filters["artist"] = request.Filters.Artist
+	}
+	if len(filters) > 0 {
+		event.Filters = filters
+	}
+
+	// Reranking and diversification reorder hits, so the best retrieval
+	// distance or score is not necessarily the first card's
+	for _, card := range cards {
+		additional, ok := card["_additional"].(map[string]any)
+		if !ok {
+			continue
+		}
+		if distance, ok := additional["distance"].(float64); ok && (event.TopDistance == nil || distance < *event.TopDistance) {
+			event.TopDistance = &distance
+		}
+		if score := parseScore(additional["score"]); score != nil && (event.TopScore == nil || *score > *event.TopScore) {
+			event.TopScore = score
+		}
+	}
+
+	if err := searchLog.Record(event); err != nil {
+		slog.WarnContext(ctx, "Could not record search analytics", "error", err.Error())
+	}
+}
+
+// requireAdmin checks the bearer token for /api/admin endpoints, which do
+// not exist as far as clients can tell unless ADMIN_TOKEN is configured.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if activeConfig.ADMIN_TOKEN == "" {
+		notFoundHandler(w, r)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(activeConfig.ADMIN_TOKEN)) != 1 {
+		writeError(w, r, http.StatusUnauthorized, APIError{Code: errCodeUnauthorized, Message: "A valid admin bearer token is required"})
+		return false
+	}
+	return true
+}
+
+func analyticsReportHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	window := defaultReportWindow
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "since must be a positive duration such as 24h", Field: "since"})
+			return
+		}
+		window = parsed
+	}
+	limit := defaultReportLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxReportLimit {
+			writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "limit must be between 1 and 200", Field: "limit"})
+			return
+		}
+		limit = parsed
+	}
+	threshold := poorDistance()
+	if value := query.Get("poor_distance"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 2 {
+			writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "poor_distance must be between 0 and 2", Field: "poor_distance"})
+			return
+		}
+		threshold = parsed
+	}
+
+	since := time.Now().UTC().Add(-window)
+	events, err := analytics.ReadEvents(analyticsDir(), since)
+	if err != nil {
+		writeErrorFrom(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, analytics.BuildReport(events, since, threshold, limit))
+}
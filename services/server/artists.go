@@ -0,0 +1,225 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"mtguru/packages/cardquery"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+)
+
+const defaultArtistsLimit = 20
+
+// maxArtistCards bounds the printings scanned for one artist lookup or
+// page, well under Weaviate's result limit.
+const maxArtistCards = 5000
+
+type MTGuruArtist struct {
+	ID    string `json:"id" doc:"Scryfall artist id"`
+	Name  string `json:"name"`
+	Cards int    `json:"cards" doc:"Printings the artist illustrated"`
+}
+
+type MTGuruArtistList struct {
+	Artists []MTGuruArtist `json:"artists"`
+}
+
+type ArtistSetCount struct {
+	Set     string `json:"set"`
+	SetName string `json:"set_name"`
+	Count   int    `json:"count"`
+}
+
+type ArtistYearCount struct {
+	Year  int `json:"year"`
+	Count int `json:"count"`
+}
+
+type MTGuruArtistCards struct {
+	Artist MTGuruArtist      `json:"artist"`
+	Cards  []CardRecord      `json:"cards" doc:"Every printing the artist illustrated, newest first"`
+	BySet  []ArtistSetCount  `json:"by_set" doc:"Printing counts per set, most first"`
+	ByYear []ArtistYearCount `json:"by_year" doc:"Printing counts per release year, oldest first"`
+}
+
+// artistCard is a printing with the fields artist pages need beyond CardRecord.
+type artistCard struct {
+	card       CardRecord
+	artist     string
+	artistIDs  []string
+	releasedAt string
+}
+
+func fetchArtistCards(ctx context.Context, where *filters.WhereBuilder) ([]artistCard, error) {
+	response, err := client.GraphQL().Get().
+		WithClassName(printingsClass).
+		WithFields(printingFields([]string{"artist", "artist_ids", "released_at"}, "id")...).
+		WithWhere(where).
+		WithLimit(maxArtistCards).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+
+	results := resultObjects(response, printingsClass)
+	cards := make([]artistCard, 0, len(results))
+	for _, result := range results {
+		card := artistCard{card: cardRecordFromResult(result)}
+		card.artist, _ = result["artist"].(string)
+		card.releasedAt, _ = result["released_at"].(string)
+		ids, _ := result["artist_ids"].([]any)
+		for _, id := range ids {
+			if id, ok := id.(string); ok {
+				card.artistIDs = append(card.artistIDs, id)
+			}
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// artistNames pairs a card's artist ids with its names. Scryfall joins
+// collaborators as "A & B" in the same order as artist_ids; when the counts
+// disagree every id gets the whole string.
+func artistNames(card artistCard) map[string]string {
+	names := strings.Split(card.artist, " & ")
+	if len(names) != len(card.artistIDs) {
+		names = slices.Repeat([]string{card.artist}, len(card.artistIDs))
+	}
+	byID := make(map[string]string, len(card.artistIDs))
+	for i, id := range card.artistIDs {
+		byID[id] = strings.TrimSpace(names[i])
+	}
+	return byID
+}
+
+func containsAllWords(text string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func artistsHandler(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	name := strings.TrimSpace(query.Get("q"))
+	if name == "" {
+		writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "q is required", Field: "q"})
+		return
+	}
+	limit := defaultArtistsLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 100 {
+			writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "limit must be between 1 and 100", Field: "limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	terms := []cardquery.Term{{Field: cardquery.FieldArtist, Operator: cardquery.OpContains, Value: strings.ToLower(name)}}
+	cards, err := fetchArtistCards(ctx, filters.Where().WithOperator(filters.And).WithOperands(cardQueryWhere(terms)))
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	// Collaborations match on either name, so keep only the artists asked for
+	words := strings.Fields(strings.ToLower(name))
+	artists := map[string]*MTGuruArtist{}
+	for _, card := range cards {
+		for id, artistName := range artistNames(card) {
+			if containsAllWords(strings.ToLower(artistName), words) {
+				if artists[id] == nil {
+					artists[id] = &MTGuruArtist{ID: id, Name: artistName}
+				}
+				artists[id].Cards++
+			}
+		}
+	}
+
+	response := MTGuruArtistList{Artists: []MTGuruArtist{}}
+	for _, artist := range artists {
+		response.Artists = append(response.Artists, *artist)
+	}
+	slices.SortFunc(response.Artists, func(a, b MTGuruArtist) int {
+		return cmp.Or(cmp.Compare(b.Cards, a.Cards), strings.Compare(a.Name, b.Name))
+	})
+	response.Artists = response.Artists[:min(limit, len(response.Artists))]
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func artistCardsHandler(w http.ResponseWriter, r *http.Request) {
+
+	id := r.PathValue("id")
+	if !uuidPattern.MatchString(id) {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No artist with id " + id})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	cards, err := fetchArtistCards(ctx, filters.Where().
+		WithPath([]string{"artist_ids"}).
+		WithOperator(filters.ContainsAny).
+		WithValueString(id))
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+	if len(cards) == 0 {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No artist with id " + id})
+		return
+	}
+
+	// Newest first, so the artist's name is their latest credit
+	slices.SortStableFunc(cards, func(a, b artistCard) int {
+		return cmp.Or(strings.Compare(b.releasedAt, a.releasedAt), strings.Compare(a.card.Name, b.card.Name))
+	})
+
+	response := MTGuruArtistCards{
+		Artist: MTGuruArtist{ID: id, Name: artistNames(cards[0])[id], Cards: len(cards)},
+		Cards:  make([]CardRecord, 0, len(cards)),
+		BySet:  []ArtistSetCount{},
+		ByYear: []ArtistYearCount{},
+	}
+	bySet := map[string]*ArtistSetCount{}
+	byYear := map[int]int{}
+	for _, card := range cards {
+		response.Cards = append(response.Cards, card.card)
+		if bySet[card.card.Set] == nil {
+			bySet[card.card.Set] = &ArtistSetCount{Set: card.card.Set, SetName: card.card.SetName}
+		}
+		bySet[card.card.Set].Count++
+		if year, err := strconv.Atoi(card.releasedAt[:min(4, len(card.releasedAt))]); err == nil {
+			byYear[year]++
+		}
+	}
+	for _, count := range bySet {
+		response.BySet = append(response.BySet, *count)
+	}
+	slices.SortFunc(response.BySet, func(a, b ArtistSetCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.SetName, b.SetName))
+	})
+	for year, count := range byYear {
+		response.ByYear = append(response.ByYear, ArtistYearCount{Year: year, Count: count})
+	}
+	slices.SortFunc(response.ByYear, func(a, b ArtistYearCount) int { return cmp.Compare(a.Year, b.Year) })
+
+	writeJSON(w, http.StatusOK, response)
+}
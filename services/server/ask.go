@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mtguru/packages/config"
+	"mtguru/packages/llm"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultAskCards      = 8
+	maxAskRulingsPerCard = 3
+)
+
+const askSystemPrompt = `You are MTGuru, an assistant for Magic: The Gathering card questions.
+Answer only from the numbered cards and the rulings listed under them. Cite every card you rely on with its number in square brackets, e.g. [2].
+If the cards given do not answer the question, say so instead of guessing.`
+
+type MTGuruAskRequest struct {
+	Question string                     `json:"question" openapi:"required,minLength=1,maxLength=1000"`
+	Filters  MTGuruSearchRequestFilters `json:"filters"`
+	Cards    int                        `json:"cards" openapi:"minimum=0,maximum=20" doc:"Number of cards retrieved as context, 0 uses the default"`
+}
+
+type AskCitation struct {
+	Index  int    `json:"index" doc:"The [n] marker used in the answer"`
+	CardID string `json:"card_id"`
+	Name   string `json:"name"`
+}
+
+type MTGuruAskResponse struct {
+	Answer    string        `json:"answer"`
+	Citations []AskCitation `json:"citations"`
+	Cards     []CardRecord  `json:"cards" doc:"Every card given to the model, in citation order"`
+	Model     string        `json:"model"`
+}
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+var answerer llm.LLM
+
+// createLLM picks the answer backend named by PROVIDER. The stub has to be
+// asked for by name; without a usable provider it returns nil and /api/ask
+// answers 503 rather than serving canned answers as if they were real.
+func createLLM(conf config.EnvironmentConfig) llm.LLM {
+	switch conf.LLM.PROVIDER {
+	case "openai":
+		baseURL := conf.LLM.BASE_URL
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		apiKey := conf.LLM.API_KEY
+		if apiKey == "" {
+			apiKey = conf.OPEN_API_KEY
+		}
+		model := conf.LLM.MODEL
+		if model == "" {
+			model = "gpt-4o-mini"
+		}
+		return llm.NewOpenAICompatible(baseURL, apiKey, model)
+	case "stub":
+		return llm.Stub{}
+	case "":
+		slog.Error("No LLM provider configured, /api/ask is disabled; set [llm] PROVIDER to \"openai\" or \"stub\"")
+		return nil
+	default:
+		slog.Error("Unknown LLM provider, /api/ask is disabled", "provider", conf.LLM.PROVIDER)
+		return nil
+	}
+}
+
+// buildAskPrompt numbers the retrieved cards so the model can cite them and
+// lists the latest rulings of each under it.
+func buildAskPrompt(question string, cards []CardRecord, rulings map[string][]CardRuling) []llm.Message {
+	var prompt strings.Builder
+	for i, card := range cards {
+		fmt.Fprintf(&prompt, "[%d] %s (%s)\n", i+1, card.Name, card.ID)
+		if card.ManaCost != "" {
+			fmt.Fprintf(&prompt, "Mana cost: %s\n", card.ManaCost)
+		}
+		fmt.Fprintf(&prompt, "Type: %s\n", card.TypeLine)
+		if card.OracleText != "" {
+			fmt.Fprintf(&prompt, "Text: %s\n", card.OracleText)
+		}
+		cardRulings := rulings[card.OracleID]
+		for _, ruling := range cardRulings[max(0, len(cardRulings)-maxAskRulingsPerCard):] {
+			fmt.Fprintf(&prompt, "Ruling (%s): %s\n", ruling.PublishedAt, ruling.Comment)
+		}
+		prompt.WriteString("\n")
+	}
+
+	return []llm.Message{
+		{Role: llm.RoleSystem, Content: askSystemPrompt},
+		{Role: llm.RoleUser, Content: "Cards:\n\n" + prompt.String() + "Question: " + question},
+	}
+}
+
+// parseCitations maps the [n] markers in an answer back to card ids, in the
+// order they first appear. Markers outside the card list are ignored.
+func parseCitations(answer string, cards []CardRecord) []AskCitation {
+	citations := []AskCitation{}
+	seen := map[int]bool{}
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		index, err := strconv.Atoi(match[1])
+		if err != nil || index < 1 || index > len(cards) || seen[index] {
+			continue
+		}
+		seen[index] = true
+		card := cards[index-1]
+		citations = append(citations, AskCitation{Index: index, CardID: card.ID, Name: card.Name})
+	}
+	return citations
+}
+
+func retrieveAskCards(ctx context.Context, request MTGuruAskRequest, limit int) ([]CardRecord, error) {
+	response, err := executeSearch(ctx, MTGuruSearchRequest{
+		Query:   request.Question,
+		Filters: request.Filters,
+		Mode:    searchModeSemantic,
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := []CardRecord{}
+	for _, result := range response.cards() {
+		cards = append(cards, cardRecordFromResult(result))
+	}
+	return cards, nil
+}
+
+func askHandler(w http.ResponseWriter, r *http.Request) {
+
+	var requestBody MTGuruAskRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if answerer == nil {
+		writeError(w, r, http.StatusServiceUnavailable, APIError{Code: errCodeLLMUnavailable, Message: "No LLM provider is configured"})
+		return
+	}
+
+	limit := requestBody.Cards
+	if limit <= 0 {
+		limit = defaultAskCards
+	}
+	slog.InfoContext(r.Context(), "Received ask request:", "question", requestBody.Question, "cards", limit)
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	cards, err := retrieveAskCards(ctx, requestBody, limit)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		cancel()
+		return
+	}
+
+	oracleIDs := make([]string, 0, len(cards))
+	for _, card := range cards {
+		oracleIDs = append(oracleIDs, card.OracleID)
+	}
+	// Rulings improve the answer but are optional, e.g. before they are ingested
+	rulings, err := fetchRulings(ctx, oracleIDs)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Could not fetch rulings for ask", "error", err.Error())
+	}
+	cancel()
+
+	answer, err := answerer.Complete(r.Context(), buildAskPrompt(requestBody.Question, cards, rulings))
+	if err != nil {
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			writeErrorFrom(w, r, newHTTPError(statusClientClosedRequest, errCodeClientClosed, "Client closed request", err))
+			return
+		}
+		writeErrorFrom(w, r, newHTTPError(http.StatusBadGateway, errCodeLLMFailed, "Could not generate an answer", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MTGuruAskResponse{
+		Answer:    answer,
+		Citations: parseCitations(answer, cards),
+		Cards:     cards,
+		Model:     answerer.Model(),
+	})
+}
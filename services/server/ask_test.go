@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"mtguru/packages/config"
+	"mtguru/packages/llm"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var askTestCards = []CardRecord{
+	{ID: "id-1", OracleID: "oracle-1", Name: "Lightning Bolt", ManaCost: "{R}", TypeLine: "Instant", OracleText: "Lightning Bolt deals 3 damage to any target."},
+	{ID: "id-2", OracleID: "oracle-2", Name: "Opt", ManaCost: "{U}", TypeLine: "Instant", OracleText: "Scry 1. Draw a card."},
+	{ID: "id-3", OracleID: "oracle-3", Name: "Island", TypeLine: "Basic Land — Island"},
+}
+
+func TestParseCitations(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   []AskCitation
+	}{
+		{"none", "No card answers that.", []AskCitation{}},
+		{"first appearance order", "Use Opt [2] or Bolt [1].", []AskCitation{
+			{Index: 2, CardID: "id-2", Name: "Opt"},
+			{Index: 1, CardID: "id-1", Name: "Lightning Bolt"},
+		}},
+		{"repeated marker", "[3] and [3] again", []AskCitation{
+			{Index: 3, CardID: "id-3", Name: "Island"},
+		}},
+		{"out of range", "[0] [4] [12] [2]", []AskCitation{
+			{Index: 2, CardID: "id-2", Name: "Opt"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseCitations(test.answer, askTestCards); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseCitations(%q) = %+v, want %+v", test.answer, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildAskPrompt(t *testing.T) {
+	rulings := map[string][]CardRuling{
+		"oracle-1": {
+			{PublishedAt: "2020-01-01", Comment: "oldest"},
+			{PublishedAt: "2021-01-01", Comment: "second"},
+			{PublishedAt: "2022-01-01", Comment: "third"},
+			{PublishedAt: "2023-01-01", Comment: "newest"},
+		},
+	}
+	messages := buildAskPrompt("Which instant draws?", askTestCards, rulings)
+
+	if len(messages) != 2 || messages[0].Role != llm.RoleSystem || messages[1].Role != llm.RoleUser {
+		t.Fatalf("buildAskPrompt roles = %+v, want system then user", messages)
+	}
+	prompt := messages[1].Content
+	for _, want := range []string{
+		"[1] Lightning Bolt (id-1)\nMana cost: {R}\nType: Instant\nText: Lightning Bolt deals 3 damage to any target.\n",
+		"[2] Opt (id-2)\n",
+		"[3] Island (id-3)\nType: Basic Land — Island\n\n",
+		"Ruling (2023-01-01): newest",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "oldest") {
+		t.Errorf("prompt has more than %d rulings per card:\n%s", maxAskRulingsPerCard, prompt)
+	}
+	if !strings.HasSuffix(prompt, "Question: Which instant draws?") {
+		t.Errorf("prompt does not end with the question:\n%s", prompt)
+	}
+
+	// The stub cites every numbered card, so each one maps back to its id
+	answer, err := llm.Stub{}.Complete(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	citations := parseCitations(answer, askTestCards)
+	if len(citations) != len(askTestCards) {
+		t.Fatalf("stub answer %q cites %+v, want every card", answer, citations)
+	}
+	for i, citation := range citations {
+		if citation.CardID != askTestCards[i].ID {
+			t.Errorf("citation %d = %+v, want card %s", i, citation, askTestCards[i].ID)
+		}
+	}
+}
+
+func TestCreateLLMRequiresProvider(t *testing.T) {
+	var conf config.EnvironmentConfig
+	if got := createLLM(conf); got != nil {
+		t.Errorf("createLLM without a provider = %T, want nil", got)
+	}
+	conf.LLM.PROVIDER = "nonsense"
+	if got := createLLM(conf); got != nil {
+		t.Errorf("createLLM with an unknown provider = %T, want nil", got)
+	}
+	conf.LLM.PROVIDER = "stub"
+	if _, ok := createLLM(conf).(llm.Stub); !ok {
+		t.Errorf("createLLM with PROVIDER = stub did not return the stub")
+	}
+}
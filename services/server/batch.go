@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"mtguru/packages/config"
+	"mtguru/packages/custom_logger"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultBatchSearchConcurrency = 4
+
+type MTGuruBatchSearchRequest struct {
+	Queries []MTGuruSearchRequest `json:"queries" openapi:"required,maxItems=50" doc:"Searches to run, answered in the same order"`
+}
+
+// MTGuruBatchSearchResult holds the outcome of one query. Exactly one of
+// Results and Error is set, so one failing query never fails the batch.
+type MTGuruBatchSearchResult struct {
+	Index   int                   `json:"index"`
+	Query   string                `json:"query"`
+	Results *MTGuruSearchResponse `json:"results,omitempty"`
+	Error   *APIError             `json:"error,omitempty"`
+}
+
+type MTGuruBatchSearchResponse struct {
+	Results   []MTGuruBatchSearchResult `json:"results"`
+	Succeeded int                       `json:"succeeded"`
+	Failed    int                       `json:"failed"`
+}
+
+func batchSearchConcurrency(conf config.EnvironmentConfig) int {
+	if conf.BATCH_SEARCH_CONCURRENCY <= 0 {
+		return defaultBatchSearchConcurrency
+	}
+	return conf.BATCH_SEARCH_CONCURRENCY
+}
+
+func runBatchSearch(requestCtx context.Context, queries []MTGuruSearchRequest, concurrency int) MTGuruBatchSearchResponse {
+	results := make([]MTGuruBatchSearchResult, len(queries))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query MTGuruSearchRequest) {
+			defer wg.Done()
+			results[i] = MTGuruBatchSearchResult{Index: i, Query: query.Query}
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-requestCtx.Done():
+				httpErr := classifySearchError(requestCtx, requestCtx, requestCtx.Err())
+				results[i].Error = &APIError{Code: httpErr.code, Message: httpErr.message}
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(requestCtx, searchTimeout(activeConfig))
+			defer cancel()
+
+			start := time.Now()
+			response, err := executeSearch(ctx, query, searchLimit(query.Limit))
+			if err != nil {
+				httpErr := classifySearchError(requestCtx, ctx, err)
+				recordSearch(requestCtx, searchSourceBatch, query, start, nil, httpErr.code)
+				slog.WarnContext(requestCtx, "Batch query failed", "index", i, "code", httpErr.code, "error", err.Error())
+				results[i].Error = &APIError{
+					Code:      httpErr.code,
+					Message:   httpErr.message,
+					RequestID: custom_logger.RequestIDFromContext(requestCtx),
+				}
+				return
+			}
+			recordSearch(requestCtx, searchSourceBatch, query, start, response.cards(), "")
+			results[i].Results = &response
+		}(i, query)
+	}
+	wg.Wait()
+
+	batch := MTGuruBatchSearchResponse{Results: results}
+	for _, result := range results {
+		if result.Error != nil {
+			batch.Failed++
+		} else {
+			batch.Succeeded++
+		}
+	}
+	return batch
+}
+
+func batchSearchHandler(w http.ResponseWriter, r *http.Request) {
+
+	var requestBody MTGuruBatchSearchRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Received batch search request:", "queries", len(requestBody.Queries))
+
+	batch := runBatchSearch(r.Context(), requestBody.Queries, batchSearchConcurrency(activeConfig))
+	if r.Context().Err() != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), r.Context(), r.Context().Err()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
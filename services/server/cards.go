@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// namesPerLookup bounds how many name operands go into one Or filter.
+const namesPerLookup = 50
+
+// CardFace is one face of a multi-faced card. The card's top-level text
+// fields join every face with " // ".
+type CardFace struct {
+	Name       string            `json:"name"`
+	ManaCost   string            `json:"mana_cost"`
+	TypeLine   string            `json:"type_line"`
+	OracleText string            `json:"oracle_text,omitempty"`
+	Colors     []string          `json:"colors,omitempty"`
+	Power      string            `json:"power,omitempty"`
+	Toughness  string            `json:"toughness,omitempty"`
+	Loyalty    string            `json:"loyalty,omitempty"`
+	Defense    string            `json:"defense,omitempty"`
+	ImageURIs  map[string]string `json:"image_uris,omitempty"`
+}
+
+// CardRecord is the subset of an Mtguru object the server works with when it
+// needs typed access to a card, e.g. for deck analysis.
+type CardRecord struct {
+	ID              string            `json:"id"`
+	ScryfallID      string            `json:"scryfall_id"`
+	OracleID        string            `json:"oracle_id"`
+	Name            string            `json:"name"`
+	ManaCost        string            `json:"mana_cost"`
+	Cmc             float64           `json:"cmc"`
+	TypeLine        string            `json:"type_line"`
+	OracleText      string            `json:"oracle_text,omitempty"`
+	Colors          []string          `json:"colors"`
+	ColorIdentity   []string          `json:"color_identity"`
+	SetName         string            `json:"set_name,omitempty"`
+	Set             string            `json:"set,omitempty"`
+	CollectorNumber string            `json:"collector_number,omitempty"`
+	MtgoID          int               `json:"mtgo_id,omitempty"`
+	Rarity          string            `json:"rarity,omitempty"`
+	Layout          string            `json:"layout,omitempty"`
+	CardFaces       []CardFace        `json:"card_faces,omitempty"`
+	ScryfallURI     string            `json:"scryfall_uri,omitempty"`
+	ImageURIs       map[string]string `json:"image_uris,omitempty"`
+}
+
+var cardRecordFields = []graphql.Field{
+	{Name: "scryfall_id"},
+	{Name: "oracle_id"},
+	{Name: "name"},
+	{Name: "mana_cost"},
+	{Name: "cmc"},
+	{Name: "type_line"},
+	{Name: "oracle_text"},
+	{Name: "colors"},
+	{Name: "color_identity"},
+	{Name: "set_name"},
+	{Name: "set"},
+	{Name: "collector_number"},
+	{Name: "mtgo_id"},
+	{Name: "rarity"},
+	{Name: "layout"},
+	cardFacesField,
+	{Name: "scryfall_uri"},
+	{Name: "image_uris", Fields: []graphql.Field{
+		{Name: "normal"},
+		{Name: "large"},
+	}},
+}
+
+var cardFacesField = graphql.Field{Name: "card_faces", Fields: []graphql.Field{
+	{Name: "name"},
+	{Name: "mana_cost"},
+	{Name: "type_line"},
+	{Name: "oracle_text"},
+	{Name: "colors"},
+	{Name: "power"},
+	{Name: "toughness"},
+	{Name: "loyalty"},
+	{Name: "defense"},
+	{Name: "image_uris", Fields: []graphql.Field{
+		{Name: "normal"},
+		{Name: "large"},
+	}},
+}}
+
+// cardFields returns the cardRecordFields the collection has, plus the
+// requested _additional fields.
+func cardFields(additional ...string) []graphql.Field {
+	fields := append([]graphql.Field{}, presentFields(cardRecordFields)...)
+	additionalFields := make([]graphql.Field, 0, len(additional))
+	for _, name := range additional {
+		additionalFields = append(additionalFields, graphql.Field{Name: name})
+	}
+	return append(fields, graphql.Field{Name: "_additional", Fields: additionalFields})
+}
+
+// printingsClass is written by the ingestion service from Scryfall's default
+// cards, one object per printing, for set and artist pages.
+const printingsClass = "MtguruPrinting"
+
+// printingFields returns cardRecordFields plus the given properties and
+// _additional fields, for MtguruPrinting queries.
+func printingFields(properties []string, additional ...string) []graphql.Field {
+	fields := append([]graphql.Field{}, cardRecordFields...)
+	for _, name := range properties {
+		fields = append(fields, graphql.Field{Name: name})
+	}
+	additionalFields := make([]graphql.Field, 0, len(additional))
+	for _, name := range additional {
+		additionalFields = append(additionalFields, graphql.Field{Name: name})
+	}
+	return append(fields, graphql.Field{Name: "_additional", Fields: additionalFields})
+}
+
+// cardRecordFromResult converts one GraphQL result object into a CardRecord.
+func cardRecordFromResult(result map[string]any) CardRecord {
+	var card CardRecord
+	if b, err := json.Marshal(result); err == nil {
+		json.Unmarshal(b, &card)
+	}
+	if additional, ok := result["_additional"].(map[string]any); ok {
+		card.ID, _ = additional["id"].(string)
+	}
+	return card
+}
+
+// frontFaceName returns the first face of a multi-faced card name, so
+// "Delver of Secrets" matches "Delver of Secrets // Insectile Aberration".
+func frontFaceName(name string) string {
+	front, _, _ := strings.Cut(name, " // ")
+	return strings.TrimSpace(front)
+}
+
+// maxNameMatches bounds the cards fetched for one name when the collection
+// has no name_exact property. "name" is tokenized into words, so a short name
+// like "Island" also matches every card with that word in its name.
+const maxNameMatches = 200
+
+// lookupCardsByName resolves card names against the Mtguru collection. The
+// returned map is keyed by the lower-cased requested name; names that did not
+// match are absent. Multi-faced cards match on their full name or front face.
+func lookupCardsByName(ctx context.Context, names []string) (map[string]CardRecord, error) {
+	if !mtguruProperties()["name_exact"] {
+		return lookupCardsByNameWords(ctx, names)
+	}
+
+	resolved := map[string]CardRecord{}
+	for start := 0; start < len(names); start += namesPerLookup {
+		end := min(start+namesPerLookup, len(names))
+		chunk := names[start:end]
+
+		operands := make([]*filters.WhereBuilder, 0, len(chunk))
+		for _, name := range chunk {
+			operands = append(operands, filters.Where().
+				WithPath([]string{"name_exact"}).
+				WithOperator(filters.Equal).
+				WithValueText(strings.ToLower(name)))
+		}
+
+		response, err := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(cardFields("id")...).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands(operands)).
+			WithLimit(len(chunk) * 2).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+		resolveExactNames(resolved, chunk, resultCards(response))
+	}
+
+	return resolved, nil
+}
+
+// lookupCardsByNameWords is lookupCardsByName for collections ingested
+// before name_exact existed. Each name gets its own query, so short names
+// matching many cards cannot crowd out the others, and only exact matches
+// are kept.
+func lookupCardsByNameWords(ctx context.Context, names []string) (map[string]CardRecord, error) {
+	resolved := map[string]CardRecord{}
+	for _, name := range names {
+		if _, ok := resolved[strings.ToLower(name)]; ok {
+			continue
+		}
+		response, err := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(cardFields("id")...).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands([]*filters.WhereBuilder{
+				filters.Where().
+					WithPath([]string{"name"}).
+					WithOperator(filters.Equal).
+					WithValueString(name),
+				filters.Where().
+					WithPath([]string{"name"}).
+					WithOperator(filters.Like).
+					WithValueString(frontFaceName(name) + " // *"),
+			})).
+			WithLimit(maxNameMatches).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+		resolveExactNames(resolved, []string{name}, resultCards(response))
+	}
+	return resolved, nil
+}
+
+// resolveExactNames adds to resolved each requested name that one of results
+// carries exactly, as its full name or front face name.
+func resolveExactNames(resolved map[string]CardRecord, names []string, results []map[string]any) {
+	byName := map[string]CardRecord{}
+	for _, result := range results {
+		card := cardRecordFromResult(result)
+		byName[strings.ToLower(card.Name)] = card
+		if _, ok := byName[strings.ToLower(frontFaceName(card.Name))]; !ok {
+			byName[strings.ToLower(frontFaceName(card.Name))] = card
+		}
+	}
+	for _, name := range names {
+		if card, ok := byName[strings.ToLower(name)]; ok {
+			resolved[strings.ToLower(name)] = card
+		}
+	}
+}
+
+// lookupCardsByMtgoID resolves Magic Online catalog ids. Only the printing
+// that was ingested carries an mtgo_id, so callers fall back to names.
+func lookupCardsByMtgoID(ctx context.Context, ids []int) (map[int]CardRecord, error) {
+	resolved := map[int]CardRecord{}
+
+	for start := 0; start < len(ids); start += namesPerLookup {
+		end := min(start+namesPerLookup, len(ids))
+
+		operands := make([]*filters.WhereBuilder, 0, end-start)
+		for _, id := range ids[start:end] {
+			operands = append(operands, filters.Where().
+				WithPath([]string{"mtgo_id"}).
+				WithOperator(filters.Equal).
+				WithValueInt(int64(id)))
+		}
+
+		response, err := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(cardFields("id")...).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands(operands)).
+			WithLimit(end - start).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+
+		for _, result := range resultCards(response) {
+			card := cardRecordFromResult(result)
+			resolved[card.MtgoID] = card
+		}
+	}
+
+	return resolved, nil
+}
+
+// printingKey identifies a printing by lower-case set code and collector
+// number, the key of lookupCardsByPrinting's result.
+func printingKey(set, collectorNumber string) string {
+	return strings.ToLower(set) + "/" + collectorNumber
+}
+
+// lookupCardsByPrinting resolves (set code, collector number) pairs, given as
+// printingKey values, against the Mtguru collection. Printings that did not
+// match are absent from the result.
+func lookupCardsByPrinting(ctx context.Context, keys []string) (map[string]CardRecord, error) {
+	resolved := map[string]CardRecord{}
+
+	for start := 0; start < len(keys); start += namesPerLookup {
+		end := min(start+namesPerLookup, len(keys))
+
+		operands := make([]*filters.WhereBuilder, 0, end-start)
+		for _, key := range keys[start:end] {
+			set, collectorNumber, _ := strings.Cut(key, "/")
+			operands = append(operands, filters.Where().
+				WithOperator(filters.And).
+				WithOperands([]*filters.WhereBuilder{
+					filters.Where().
+						WithPath([]string{"set"}).
+						WithOperator(filters.Equal).
+						WithValueString(set),
+					filters.Where().
+						WithPath([]string{"collector_number"}).
+						WithOperator(filters.Equal).
+						WithValueString(collectorNumber),
+				}))
+		}
+
+		response, err := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(cardFields("id")...).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands(operands)).
+			WithLimit(end - start).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+
+		for _, result := range resultCards(response) {
+			card := cardRecordFromResult(result)
+			resolved[printingKey(card.Set, card.CollectorNumber)] = card
+		}
+	}
+
+	return resolved, nil
+}
+
+// fetchCardVectors returns the stored vector of each object id.
+func fetchCardVectors(ctx context.Context, ids []string) (map[string][]float32, error) {
+	vectors := map[string][]float32{}
+
+	for start := 0; start < len(ids); start += namesPerLookup {
+		end := min(start+namesPerLookup, len(ids))
+
+		operands := make([]*filters.WhereBuilder, 0, end-start)
+		for _, id := range ids[start:end] {
+			operands = append(operands, filters.Where().
+				WithPath([]string{"id"}).
+				WithOperator(filters.Equal).
+				WithValueText(id))
+		}
+
+		response, err := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "id"}, {Name: "vector"}}}).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands(operands)).
+			WithLimit(end - start).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+
+		for _, result := range resultCards(response) {
+			additional, _ := result["_additional"].(map[string]any)
+			id, _ := additional["id"].(string)
+			if vector := parseVector(additional["vector"]); id != "" && vector != nil {
+				vectors[id] = vector
+			}
+		}
+	}
+
+	return vectors, nil
+}
+
+// suggestCardNames returns close name matches for a card that did not
+// resolve, using BM25 over the name property.
+func suggestCardNames(ctx context.Context, name string, limit int) ([]string, error) {
+	response, err := client.GraphQL().Get().
+		WithClassName("Mtguru").
+		WithFields(graphql.Field{Name: "name"}).
+		WithBM25(client.GraphQL().Bm25ArgBuilder().
+			WithQuery(name).
+			WithProperties("name")).
+		WithLimit(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+
+	suggestions := []string{}
+	for _, result := range resultCards(response) {
+		if suggestion, ok := result["name"].(string); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions, nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type MTGuruCardResponse struct {
+	CardRecord
+	Rulings []CardRuling `json:"rulings" doc:"Scryfall rulings for the card, oldest first; empty if rulings were not ingested"`
+}
+
+// fetchCard returns the card with the given object id, or nil if none.
+func fetchCard(ctx context.Context, id string) (*CardRecord, error) {
+	response, err := client.GraphQL().Get().
+		WithClassName("Mtguru").
+		WithFields(cardFields("id")...).
+		WithWhere(filters.Where().
+			WithPath([]string{"id"}).
+			WithOperator(filters.Equal).
+			WithValueText(id)).
+		WithLimit(1).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+
+	results := resultCards(response)
+	if len(results) == 0 {
+		return nil, nil
+	}
+	card := cardRecordFromResult(results[0])
+	return &card, nil
+}
+
+func cardHandler(w http.ResponseWriter, r *http.Request) {
+
+	id := r.PathValue("id")
+	if !uuidPattern.MatchString(id) {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No card with id " + id})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	card, err := fetchCard(ctx, id)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+	if card == nil {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No card with id " + id})
+		return
+	}
+
+	response := MTGuruCardResponse{CardRecord: *card, Rulings: []CardRuling{}}
+	// The card is still useful without its rulings, e.g. before they are ingested
+	rulings, err := fetchRulings(ctx, []string{card.OracleID})
+	if err != nil {
+		slog.WarnContext(r.Context(), "Could not fetch rulings", "oracle_id", card.OracleID, "error", err.Error())
+	} else if cardRulings, ok := rulings[card.OracleID]; ok {
+		response.Rulings = cardRulings
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log/slog"
+	"mtguru/packages/config"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+// viteDevServerOrigin is used when an environment configures no origins, so
+// local development keeps working without opening the API to every site.
+const viteDevServerOrigin = "http://localhost:5173"
+
+func createCORS(conf config.CORSConfig) *cors.Cors {
+	origins := conf.ALLOWED_ORIGINS
+	if len(origins) == 0 {
+		slog.Warn("No CORS origins configured, allowing only the Vite dev server", "origin", viteDevServerOrigin)
+		origins = []string{viteDevServerOrigin}
+	}
+
+	methods := conf.ALLOWED_METHODS
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodHead}
+	}
+
+	headers := conf.ALLOWED_HEADERS
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", requestIDHeader, userIDHeader}
+	}
+
+	return cors.New(cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   methods,
+		AllowedHeaders:   headers,
+		ExposedHeaders:   []string{requestIDHeader},
+		AllowCredentials: conf.ALLOW_CREDENTIALS,
+		MaxAge:           conf.MAX_AGE_SECONDS,
+	})
+}
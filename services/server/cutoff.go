@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// The elbow cutoff only cuts at a jump in distance at least this large and
+// at least elbowGapRatio times the average of the other jumps.
+const (
+	elbowMinGap   = 0.03
+	elbowGapRatio = 2.5
+)
+
+const (
+	cutoffMaxDistance  = "max_distance"
+	cutoffMinCertainty = "min_certainty"
+	cutoffAuto         = "auto"
+)
+
+// CutoffInfo reports whether relevance thresholds dropped hits.
+type CutoffInfo struct {
+	// Truncated is set when fewer hits are returned than would have been
+	// without the cutoff
+	Truncated bool     `json:"truncated"`
+	Reason    string   `json:"reason,omitempty" openapi:"enum=|max_distance|min_certainty|auto" doc:"The cutoff that removed the most hits"`
+	Dropped   int      `json:"dropped" doc:"Retrieved hits removed by the cutoff"`
+	Distance  *float64 `json:"distance,omitempty" doc:"Distance of the last hit kept"`
+}
+
+// cutoffRequested reports whether the request asks for a relevance cutoff.
+func cutoffRequested(request MTGuruSearchRequest) bool {
+	return request.MaxDistance > 0 || request.MinCertainty > 0 || request.AutoCutoff
+}
+
+// checkCutoff rejects cutoffs on searches that have no vector distance.
+func checkCutoff(request MTGuruSearchRequest) error {
+	if cutoffRequested(request) && request.Mode != "" && request.Mode != searchModeSemantic {
+		return newHTTPError(http.StatusBadRequest, errCodeInvalidRequest, "max_distance, min_certainty and auto_cutoff only apply to semantic search", nil)
+	}
+	return nil
+}
+
+func hitDistance(card map[string]any) (float64, bool) {
+	additional, _ := card["_additional"].(map[string]any)
+	if distance := parseScore(additional["distance"]); distance != nil {
+		return *distance, true
+	}
+	return 0, false
+}
+
+// elbowIndex returns how many of the ascending distances come before the
+// sharpest jump in the curve, or len(distances) when there is no clear jump.
+func elbowIndex(distances []float64) int {
+	if len(distances) < 3 {
+		return len(distances)
+	}
+
+	gaps := make([]float64, len(distances)-1)
+	total := 0.0
+	for i := range gaps {
+		gaps[i] = distances[i+1] - distances[i]
+		total += gaps[i]
+	}
+	largest := slices.Index(gaps, slices.Max(gaps))
+	rest := (total - gaps[largest]) / float64(len(gaps)-1)
+
+	if gaps[largest] < elbowMinGap || gaps[largest] < elbowGapRatio*rest {
+		return len(distances)
+	}
+	return largest + 1
+}
+
+// cutoffCards drops hits past the requested distance or certainty and, with
+// auto_cutoff, everything after the elbow of the distance curve. Hits come
+// in ascending distance order, so each cutoff keeps a prefix. limit is what
+// the search would have returned, to tell whether the cutoff truncated it.
+func cutoffCards(cards []map[string]any, request MTGuruSearchRequest, limit int) ([]map[string]any, CutoffInfo) {
+	distances := make([]float64, 0, len(cards))
+	for _, card := range cards {
+		distance, ok := hitDistance(card)
+		if !ok {
+			break
+		}
+		distances = append(distances, distance)
+	}
+
+	keep, reason := len(cards), ""
+	cut := func(n int, why string) {
+		if n < keep {
+			keep, reason = n, why
+		}
+	}
+	for i, distance := range distances {
+		if request.MaxDistance > 0 && distance > request.MaxDistance {
+			cut(i, cutoffMaxDistance)
+			break
+		}
+	}
+	for i, distance := range distances {
+		// Cosine certainty, as Weaviate reports it
+		if request.MinCertainty > 0 && 1-distance/2 < request.MinCertainty {
+			cut(i, cutoffMinCertainty)
+			break
+		}
+	}
+	if request.AutoCutoff {
+		cut(elbowIndex(distances), cutoffAuto)
+	}
+
+	info := CutoffInfo{
+		Truncated: keep < min(limit, len(cards)),
+		Dropped:   len(cards) - keep,
+	}
+	if info.Dropped > 0 {
+		info.Reason = reason
+	}
+	if keep > 0 && keep <= len(distances) {
+		info.Distance = &distances[keep-1]
+	}
+	return cards[:keep], info
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"mtguru/packages/decklist"
+	"net/http"
+	"strings"
+)
+
+type MTGuruDeckConvertRequest struct {
+	Content string `json:"content" openapi:"required,minLength=1,maxLength=500000" doc:"The decklist file contents"`
+	From    string `json:"from" openapi:"enum=|text|arena|mtgo|moxfield|archidekt" doc:"Source format, detected from the content when empty"`
+	To      string `json:"to" openapi:"required,enum=text|arena|mtgo|moxfield|archidekt"`
+}
+
+type MTGuruDeckConvertResponse struct {
+	From        decklist.Format       `json:"from"`
+	To          decklist.Format       `json:"to"`
+	ContentType string                `json:"content_type"`
+	Content     string                `json:"content"`
+	Entries     []DeckEntry           `json:"entries"`
+	Unresolved  []UnresolvedCard      `json:"unresolved"`
+	ParseErrors []decklist.ParseError `json:"parse_errors"`
+}
+
+// canonicalDeck rebuilds a decklist from resolved entries, taking the card
+// name, set, collector number and MTGO id from the collection so the target
+// client recognises each card. Unresolved entries are written as they came.
+func canonicalDeck(entries []DeckEntry) decklist.Decklist {
+	var deck decklist.Decklist
+	for _, entry := range entries {
+		out := entry.Entry
+		if entry.Card != nil {
+			out.Name = entry.Card.Name
+			if out.MtgoID == 0 {
+				out.MtgoID = entry.Card.MtgoID
+			}
+			if out.SetCode == "" {
+				out.SetCode = entry.Card.Set
+				out.CollectorNumber = entry.Card.CollectorNumber
+			}
+		}
+		deck.Entries = append(deck.Entries, out)
+	}
+	return deck
+}
+
+func deckConvertHandler(w http.ResponseWriter, r *http.Request) {
+
+	var requestBody MTGuruDeckConvertRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	from := decklist.Format(requestBody.From)
+	if from == "" {
+		from = decklist.Detect([]byte(requestBody.Content))
+	}
+	to := decklist.Format(requestBody.To)
+
+	deck, parseErrors, err := decklist.Parse(from, strings.NewReader(requestBody.Content))
+	if err != nil {
+		writeValidationErrors(w, r, []fieldError{{Field: "content", Message: err.Error()}})
+		return
+	}
+	slog.InfoContext(r.Context(), "Received deck conversion request:", "from", from, "to", to, "entries", len(deck.Entries))
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	entries, unresolved, err := resolveDeck(ctx, deck)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	var out bytes.Buffer
+	if err := decklist.Write(to, &out, canonicalDeck(entries)); err != nil {
+		writeErrorFrom(w, r, err)
+		return
+	}
+
+	if parseErrors == nil {
+		parseErrors = []decklist.ParseError{}
+	}
+	writeJSON(w, http.StatusOK, MTGuruDeckConvertResponse{
+		From:        from,
+		To:          to,
+		ContentType: to.ContentType(),
+		Content:     out.String(),
+		Entries:     entries,
+		Unresolved:  unresolved,
+		ParseErrors: parseErrors,
+	})
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"mtguru/packages/decklist"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const maxSuggestionsPerCard = 3
+
+// A pasted list can have dozens of misspelled names; at most this many
+// suggestion queries run at once.
+const suggestionConcurrency = 4
+
+type MTGuruDeckAnalyzeRequest struct {
+	Decklist string `json:"decklist" openapi:"required,minLength=1,maxLength=50000" doc:"Decklist as plain text (\"<quantity> <name>\" per line), Arena export, MTGO .dek or Moxfield/Archidekt CSV"`
+}
+
+// DeckEntry is a parsed decklist line together with the card it resolved to.
+type DeckEntry struct {
+	decklist.Entry
+	Card *CardRecord `json:"card,omitempty"`
+}
+
+type UnresolvedCard struct {
+	Name        string           `json:"name"`
+	Quantity    int              `json:"quantity"`
+	Section     decklist.Section `json:"section"`
+	Suggestions []string         `json:"suggestions"`
+}
+
+// DeckStats covers the main deck and commander; sideboard and maybeboard
+// cards are counted in MTGuruDeckAnalysis.Counts only.
+type DeckStats struct {
+	Cards      int            `json:"cards"`
+	Lands      int            `json:"lands"`
+	ManaCurve  map[string]int `json:"mana_curve" doc:"Non-land card count per mana value, 7 and above grouped as \"7+\""`
+	ColorPips  map[string]int `json:"color_pips" doc:"Coloured mana symbols in mana costs, keyed W, U, B, R, G, C"`
+	Types      map[string]int `json:"types" doc:"Card count per card type from the front face type line"`
+	AverageCmc float64        `json:"average_cmc" doc:"Average mana value of non-land cards"`
+}
+
+type MTGuruDeckAnalysis struct {
+	Entries     []DeckEntry           `json:"entries"`
+	Unresolved  []UnresolvedCard      `json:"unresolved"`
+	ParseErrors []decklist.ParseError `json:"parse_errors"`
+	Counts      map[string]int        `json:"counts" doc:"Total cards per section"`
+	Stats       DeckStats             `json:"stats"`
+}
+
+var (
+	manaSymbolPattern = regexp.MustCompile(`\{([^}]+)\}`)
+	pipColors         = []string{"W", "U", "B", "R", "G", "C"}
+	cardTypes         = []string{"Creature", "Instant", "Sorcery", "Artifact", "Enchantment", "Planeswalker", "Land", "Battle", "Kindred", "Tribal"}
+)
+
+// manaPips counts the coloured symbols in a mana cost. Hybrid symbols count
+// towards each of their colours and Phyrexian symbols towards their colour.
+func manaPips(manaCost string) map[string]int {
+	pips := map[string]int{}
+	for _, match := range manaSymbolPattern.FindAllStringSubmatch(manaCost, -1) {
+		for _, part := range strings.Split(match[1], "/") {
+			for _, color := range pipColors {
+				if part == color {
+					pips[color]++
+				}
+			}
+		}
+	}
+	return pips
+}
+
+// frontTypes returns the card types on the front face of a type line, e.g.
+// "Legendary Artifact Creature — Golem // ..." gives Artifact and Creature.
+func frontTypes(typeLine string) []string {
+	front := frontFaceName(typeLine)
+	supertypes, _, _ := strings.Cut(front, "—")
+	words := strings.Fields(supertypes)
+
+	var types []string
+	for _, cardType := range cardTypes {
+		for _, word := range words {
+			if word == cardType {
+				types = append(types, cardType)
+			}
+		}
+	}
+	return types
+}
+
+func isLand(card CardRecord) bool {
+	for _, cardType := range frontTypes(card.TypeLine) {
+		if cardType == "Land" {
+			return true
+		}
+	}
+	return false
+}
+
+func curveBucket(cmc float64) string {
+	if cmc >= 7 {
+		return "7+"
+	}
+	return strconv.Itoa(int(cmc))
+}
+
+func computeDeckStats(entries []DeckEntry) DeckStats {
+	stats := DeckStats{
+		ManaCurve: map[string]int{},
+		ColorPips: map[string]int{},
+		Types:     map[string]int{},
+	}
+
+	totalCmc := 0.0
+	nonLands := 0
+	for _, entry := range entries {
+		if entry.Card == nil || (entry.Section != decklist.SectionMain && entry.Section != decklist.SectionCommander) {
+			continue
+		}
+		card := *entry.Card
+		stats.Cards += entry.Quantity
+
+		for _, cardType := range frontTypes(card.TypeLine) {
+			stats.Types[cardType] += entry.Quantity
+		}
+		for color, count := range manaPips(card.ManaCost) {
+			stats.ColorPips[color] += count * entry.Quantity
+		}
+
+		if isLand(card) {
+			stats.Lands += entry.Quantity
+			continue
+		}
+		stats.ManaCurve[curveBucket(card.Cmc)] += entry.Quantity
+		totalCmc += card.Cmc * float64(entry.Quantity)
+		nonLands += entry.Quantity
+	}
+
+	if nonLands > 0 {
+		stats.AverageCmc = math.Round(totalCmc/float64(nonLands)*100) / 100
+	}
+	return stats
+}
+
+// resolveDeck resolves every entry against the collection: by MTGO catalog
+// id, then by set code and collector number when the source format carried
+// them, and by name otherwise, suggesting alternatives for names that did
+// not match.
+func resolveDeck(ctx context.Context, deck decklist.Decklist) ([]DeckEntry, []UnresolvedCard, error) {
+	var mtgoIDs []int
+	var printings []string
+	for _, entry := range deck.Entries {
+		if entry.MtgoID > 0 {
+			mtgoIDs = append(mtgoIDs, entry.MtgoID)
+		}
+		if entry.SetCode != "" && entry.CollectorNumber != "" {
+			printings = append(printings, printingKey(entry.SetCode, entry.CollectorNumber))
+		}
+	}
+	byMtgoID := map[int]CardRecord{}
+	if len(mtgoIDs) > 0 {
+		var err error
+		byMtgoID, err = lookupCardsByMtgoID(ctx, mtgoIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	byPrinting := map[string]CardRecord{}
+	if len(printings) > 0 {
+		var err error
+		byPrinting, err = lookupCardsByPrinting(ctx, printings)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// resolvedByID finds an entry's card without looking at its name
+	resolvedByID := func(entry decklist.Entry) (CardRecord, bool) {
+		if card, ok := byMtgoID[entry.MtgoID]; ok {
+			return card, true
+		}
+		card, ok := byPrinting[printingKey(entry.SetCode, entry.CollectorNumber)]
+		return card, ok
+	}
+
+	var names []string
+	for _, entry := range deck.Entries {
+		if _, ok := resolvedByID(entry); !ok {
+			names = append(names, entry.Name)
+		}
+	}
+	byName, err := lookupCardsByName(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var missing []string
+	for _, entry := range deck.Entries {
+		_, byID := resolvedByID(entry)
+		if _, ok := byName[strings.ToLower(entry.Name)]; !ok && !byID {
+			missing = append(missing, entry.Name)
+		}
+	}
+	suggestionsByName, err := suggestMissingNames(ctx, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := make([]DeckEntry, 0, len(deck.Entries))
+	unresolved := []UnresolvedCard{}
+	for _, entry := range deck.Entries {
+		deckEntry := DeckEntry{Entry: entry}
+		card, ok := resolvedByID(entry)
+		if !ok {
+			card, ok = byName[strings.ToLower(entry.Name)]
+		}
+		if ok {
+			deckEntry.Card = &card
+		} else {
+			unresolved = append(unresolved, UnresolvedCard{
+				Name:        entry.Name,
+				Quantity:    entry.Quantity,
+				Section:     entry.Section,
+				Suggestions: suggestionsByName[strings.ToLower(entry.Name)],
+			})
+		}
+		entries = append(entries, deckEntry)
+	}
+	return entries, unresolved, nil
+}
+
+// suggestMissingNames looks up suggestions for each distinct name, keyed by
+// lower-case name, running at most suggestionConcurrency queries at once.
+func suggestMissingNames(ctx context.Context, names []string) (map[string][]string, error) {
+	seen := map[string]bool{}
+	var distinct []string
+	for _, name := range names {
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, name)
+		}
+	}
+
+	found := make([][]string, len(distinct))
+	errs := make([]error, len(distinct))
+	semaphore := make(chan struct{}, suggestionConcurrency)
+	var wg sync.WaitGroup
+	for i, name := range distinct {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			found[i], errs[i] = suggestCardNames(ctx, name, maxSuggestionsPerCard)
+		}(i, name)
+	}
+	wg.Wait()
+
+	suggestions := make(map[string][]string, len(distinct))
+	for i, name := range distinct {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		suggestions[strings.ToLower(name)] = found[i]
+	}
+	return suggestions, nil
+}
+
+// readDecklistBody accepts either a JSON {"decklist": "..."} body or the
+// decklist itself sent as text/plain.
+func readDecklistBody(r *http.Request) (string, []fieldError) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBodyBytes))
+		if err != nil {
+			return "", []fieldError{{Field: "", Message: "could not read request body"}}
+		}
+		if strings.TrimSpace(string(body)) == "" {
+			return "", []fieldError{{Field: "decklist", Message: "is required"}}
+		}
+		return string(body), nil
+	}
+
+	var requestBody MTGuruDeckAnalyzeRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		return "", errs
+	}
+	return requestBody.Decklist, nil
+}
+
+func deckAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+
+	text, errs := readDecklistBody(r)
+	if errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	deck, parseErrors, err := decklist.Parse(decklist.Detect([]byte(text)), strings.NewReader(text))
+	if err != nil {
+		writeValidationErrors(w, r, []fieldError{{Field: "decklist", Message: err.Error()}})
+		return
+	}
+	slog.InfoContext(r.Context(), "Received deck analysis request:", "entries", len(deck.Entries), "parse_errors", len(parseErrors))
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	entries, unresolved, err := resolveDeck(ctx, deck)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	if parseErrors == nil {
+		parseErrors = []decklist.ParseError{}
+	}
+	counts := map[string]int{}
+	for _, entry := range deck.Entries {
+		counts[string(entry.Section)] += entry.Quantity
+	}
+
+	writeJSON(w, http.StatusOK, MTGuruDeckAnalysis{
+		Entries:     entries,
+		Unresolved:  unresolved,
+		ParseErrors: parseErrors,
+		Counts:      counts,
+		Stats:       computeDeckStats(entries),
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+const defaultMMRLambda = 0.7
+
+// SearchDiversify asks for maximal marginal relevance selection, trading
+// relevance for variety so near-identical cards don't fill the results.
+type SearchDiversify struct {
+	Enabled bool `json:"enabled"`
+	// Lambda weighs relevance against novelty, 1 keeps relevance order. It is
+	// a pointer so 0, pure novelty, can be told apart from leaving it out.
+	Lambda      *float64 `json:"lambda" openapi:"minimum=0,maximum=1" doc:"1 is pure relevance, 0 pure diversity; absent or null uses 0.7"`
+	MaxPerType  int      `json:"max_per_type" openapi:"minimum=0,maximum=200" doc:"At most this many results per card type (Creature, Instant, ...), 0 for no quota"`
+	MaxPerColor int      `json:"max_per_color" openapi:"minimum=0,maximum=200" doc:"At most this many results per colour (W, U, B, R, G, or C for colourless), 0 for no quota"`
+}
+
+// DiversifyInfo reports how a search was diversified.
+type DiversifyInfo struct {
+	Lambda     float64 `json:"lambda"`
+	Candidates int     `json:"candidates"`
+	// QuotaSkipped counts candidates left out only because a quota was full
+	QuotaSkipped int `json:"quota_skipped"`
+}
+
+func (d SearchDiversify) lambda() float64 {
+	if d.Lambda == nil {
+		return defaultMMRLambda
+	}
+	return *d.Lambda
+}
+
+// relevanceScores scales each hit's relevance to 0..1 for MMR, using the
+// rerank score when the hits were reranked and retrieval relevance otherwise.
+func relevanceScores(cards []map[string]any) []float64 {
+	scores := retrievalScores(cards)
+	reranked := make([]float64, 0, len(cards))
+	for _, card := range cards {
+		if score, ok := card["rerank"].(RerankScore); ok {
+			reranked = append(reranked, score.Score)
+		}
+	}
+	if len(reranked) != len(cards) || len(cards) == 0 {
+		return scores
+	}
+
+	low, high := slices.Min(reranked), slices.Max(reranked)
+	for i, score := range reranked {
+		scores[i] = 1
+		if high > low {
+			scores[i] = (score - low) / (high - low)
+		}
+	}
+	return scores
+}
+
+func colorKeys(card CardRecord) []string {
+	if len(card.Colors) == 0 {
+		return []string{"C"}
+	}
+	return card.Colors
+}
+
+// withinQuota reports whether adding card keeps every type and colour count
+// at or under its quota.
+func withinQuota(card CardRecord, options SearchDiversify, types map[string]int, colors map[string]int) bool {
+	if options.MaxPerType > 0 {
+		for _, cardType := range frontTypes(card.TypeLine) {
+			if types[cardType] >= options.MaxPerType {
+				return false
+			}
+		}
+	}
+	if options.MaxPerColor > 0 {
+		for _, color := range colorKeys(card) {
+			if colors[color] >= options.MaxPerColor {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// diversifyCards picks up to limit hits by maximal marginal relevance:
+// each step takes the candidate maximising
+//
+//	lambda*relevance - (1-lambda)*max cosine similarity to those already picked
+//
+// skipping candidates that would break a quota. Hits need _additional.vector,
+// which is removed from every hit afterwards. Quotas can leave fewer than
+// limit hits.
+func diversifyCards(cards []map[string]any, options SearchDiversify, limit int) ([]map[string]any, DiversifyInfo) {
+	lambda := options.lambda()
+	info := DiversifyInfo{Lambda: lambda, Candidates: len(cards)}
+
+	relevance := relevanceScores(cards)
+	vectors := make([][]float32, len(cards))
+	records := make([]CardRecord, len(cards))
+	for i, card := range cards {
+		if additional, ok := card["_additional"].(map[string]any); ok {
+			vectors[i] = parseVector(additional["vector"])
+			delete(additional, "vector")
+		}
+		records[i] = cardRecordFromResult(card)
+	}
+
+	// maxSimilarity[i] is candidate i's highest similarity to a picked hit
+	maxSimilarity := make([]float64, len(cards))
+	for i := range maxSimilarity {
+		maxSimilarity[i] = math.Inf(-1)
+	}
+	picked := make([]bool, len(cards))
+	types, colors := map[string]int{}, map[string]int{}
+	quotaSkipped := map[int]bool{}
+
+	selected := make([]map[string]any, 0, min(limit, len(cards)))
+	for len(selected) < limit {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range cards {
+			if picked[i] {
+				continue
+			}
+			if !withinQuota(records[i], options, types, colors) {
+				quotaSkipped[i] = true
+				continue
+			}
+			redundancy := 0.0
+			if len(selected) > 0 {
+				redundancy = maxSimilarity[i]
+			}
+			if score := lambda*relevance[i] - (1-lambda)*redundancy; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		picked[best] = true
+		delete(quotaSkipped, best)
+		selected = append(selected, cards[best])
+		for _, cardType := range frontTypes(records[best].TypeLine) {
+			types[cardType]++
+		}
+		for _, color := range colorKeys(records[best]) {
+			colors[color]++
+		}
+		for i := range cards {
+			if !picked[i] {
+				maxSimilarity[i] = math.Max(maxSimilarity[i], cosineSimilarity(vectors[i], vectors[best]))
+			}
+		}
+	}
+
+	info.QuotaSkipped = len(quotaSkipped)
+	return selected, info
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiversifyLambda(t *testing.T) {
+	tests := []struct {
+		body string
+		want float64
+	}{
+		{`{"enabled": true}`, defaultMMRLambda},
+		{`{"enabled": true, "lambda": null}`, defaultMMRLambda},
+		{`{"enabled": true, "lambda": 0}`, 0},
+		{`{"enabled": true, "lambda": 0.4}`, 0.4},
+		{`{"enabled": true, "lambda": 1}`, 1},
+	}
+	for _, test := range tests {
+		var diversify SearchDiversify
+		if err := json.Unmarshal([]byte(test.body), &diversify); err != nil {
+			t.Fatal(err)
+		}
+		if got := diversify.lambda(); got != test.want {
+			t.Errorf("lambda() for %s = %v, want %v", test.body, got, test.want)
+		}
+	}
+}
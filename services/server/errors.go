@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mtguru/packages/custom_logger"
+	"net/http"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// Error codes returned in APIError.Code. Clients should branch on these
+// rather than on the message text.
+const (
+	errCodeInvalidRequest      = "invalid_request"
+	errCodeNotFound            = "not_found"
+	errCodeMethodNotAllowed    = "method_not_allowed"
+	errCodeMissingUser         = "missing_user"
+	errCodeUnauthorized        = "unauthorized"
+	errCodeSearchTimeout       = "search_timeout"
+	errCodeClientClosed        = "client_closed_request"
+	errCodeDatabaseUnavailable = "database_unavailable"
+	errCodeDatabaseError       = "database_error"
+	errCodeEmbeddingFailed     = "embedding_failed"
+	errCodeLLMFailed           = "llm_failed"
+	errCodeLLMUnavailable      = "llm_unavailable"
+	errCodeUpstreamFailed      = "image_upstream_failed"
+	errCodeInternal            = "internal_error"
+)
+
+// APIError is the body of every non-2xx JSON response.
+type APIError struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Field     string       `json:"field,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Details   []fieldError `json:"details,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// httpError is an error that already knows which status and code it maps to.
+type httpError struct {
+	status  int
+	code    string
+	message string
+	cause   error
+}
+
+func (e *httpError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.message, e.cause)
+	}
+	return e.message
+}
+
+func (e *httpError) Unwrap() error {
+	return e.cause
+}
+
+func newHTTPError(status int, code string, message string, cause error) *httpError {
+	return &httpError{status: status, code: code, message: message, cause: cause}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	responseJSON, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("Error marshalling response", "error", err.Error())
+		status = http.StatusInternalServerError
+		responseJSON = []byte(`{"error":{"code":"internal_error","message":"Internal server error"}}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, apiErr APIError) {
+	apiErr.RequestID = custom_logger.RequestIDFromContext(r.Context())
+	writeJSON(w, status, ErrorResponse{Error: apiErr})
+}
+
+// writeErrorFrom maps any error to the envelope. Errors that are not an
+// httpError are reported as internal errors without leaking their text.
+func writeErrorFrom(w http.ResponseWriter, r *http.Request, err error) {
+	var httpErr *httpError
+	if !errors.As(err, &httpErr) {
+		httpErr = newHTTPError(http.StatusInternalServerError, errCodeInternal, "Internal server error", err)
+	}
+
+	level := slog.LevelWarn
+	if httpErr.status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(r.Context(), level, "Request failed", "status", httpErr.status, "code", httpErr.code, "error", err.Error())
+
+	writeError(w, r, httpErr.status, APIError{Code: httpErr.code, Message: httpErr.message})
+}
+
+func writeValidationErrors(w http.ResponseWriter, r *http.Request, errs []fieldError) {
+	apiErr := APIError{
+		Code:    errCodeInvalidRequest,
+		Message: "Invalid request body",
+		Details: errs,
+	}
+	if len(errs) > 0 {
+		apiErr.Field = errs[0].Field
+		apiErr.Message = strings.TrimSpace(errs[0].Field + " " + errs[0].Message)
+	}
+	writeError(w, r, http.StatusBadRequest, apiErr)
+}
+
+// classifySearchError turns a failure from the search path into an httpError.
+// ctx is the per-search context so deadline and client cancellation can be
+// told apart from Weaviate failures.
+func classifySearchError(requestCtx context.Context, ctx context.Context, err error) *httpError {
+	if errors.Is(requestCtx.Err(), context.Canceled) {
+		return newHTTPError(statusClientClosedRequest, errCodeClientClosed, "Client closed request", err)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return newHTTPError(http.StatusGatewayTimeout, errCodeSearchTimeout, "Search timed out", err)
+	}
+
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	var clientErr *fault.WeaviateClientError
+	if errors.As(err, &clientErr) && !clientErr.IsUnexpectedStatusCode {
+		return newHTTPError(http.StatusServiceUnavailable, errCodeDatabaseUnavailable, "Card database is unavailable", err)
+	}
+	return newHTTPError(http.StatusBadGateway, errCodeDatabaseError, "Card database returned an error", err)
+}
+
+// graphQLError converts the errors Weaviate reports inside a 200 GraphQL
+// response. Vectorizer failures surface here, so they are reported as
+// embedding failures rather than generic database errors.
+func graphQLError(response *models.GraphQLResponse) error {
+	if response == nil {
+		return newHTTPError(http.StatusBadGateway, errCodeDatabaseError, "Card database returned no response", nil)
+	}
+	if len(response.Errors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(response.Errors))
+	for _, gqlErr := range response.Errors {
+		messages = append(messages, gqlErr.Message)
+	}
+	cause := errors.New(strings.Join(messages, "; "))
+
+	lower := strings.ToLower(cause.Error())
+	if strings.Contains(lower, "openai") || strings.Contains(lower, "vectoriz") || strings.Contains(lower, "vectoris") {
+		return newHTTPError(http.StatusBadGateway, errCodeEmbeddingFailed, "Could not embed the search query", cause)
+	}
+	return newHTTPError(http.StatusBadGateway, errCodeDatabaseError, "Card database returned an error", cause)
+}
+
+// recoverMiddleware turns a panicking handler into a JSON 500 instead of a
+// dropped connection.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				writeErrorFrom(w, r, fmt.Errorf("panic: %v", rec))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unmatchedHandler answers requests no route matched: 405 with an Allow
+// header when the path belongs to a route registered for other methods,
+// 404 otherwise.
+func unmatchedHandler(routes []apiRoute) http.HandlerFunc {
+	paths := http.NewServeMux()
+	methods := map[string][]string{}
+	for _, route := range routes {
+		if _, ok := methods[route.Path]; !ok {
+			paths.HandleFunc(route.Path, func(http.ResponseWriter, *http.Request) {})
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := paths.Handler(r); pattern != "" {
+			w.Header().Set("Allow", strings.Join(methods[pattern], ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, APIError{
+				Code:    errCodeMethodNotAllowed,
+				Message: fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path),
+			})
+			return
+		}
+		notFoundHandler(w, r)
+	}
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, APIError{
+		Code:    errCodeNotFound,
+		Message: fmt.Sprintf("No endpoint %s %s", r.Method, r.URL.Path),
+	})
+}
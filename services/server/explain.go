@@ -0,0 +1,205 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// explainedFields are searched for query terms, in this order.
+var explainedFields = []string{"name", "type_line", "oracle_text"}
+
+// queryStopwords are dropped before matching so "make my units fly" looks for
+// units and fly rather than highlighting every "my".
+var queryStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "any": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "card": true, "cards": true, "do": true, "for": true,
+	"from": true, "get": true, "has": true, "have": true, "i": true, "in": true, "into": true,
+	"is": true, "it": true, "its": true, "make": true, "me": true, "my": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "their": true, "them": true, "to": true,
+	"that's": true, "with": true, "you": true, "your": true, "which": true, "what": true,
+}
+
+// Hybrid explainScore lines look like
+// "(Result Set keyword,bm25) Document 6f...: original score 2.1, normalized score: 0.7"
+var explainScorePattern = regexp.MustCompile(`\(Result Set (\w+)[^)]*\)[^:]*:\s*original score ([-+\d.eE]+), normalized score: ([-+\d.eE]+)`)
+
+// SearchHighlight marks one query term match. Start and End are character
+// (not byte) offsets into the field value, End exclusive.
+type SearchHighlight struct {
+	Field    string `json:"field"`
+	Term     string `json:"term"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Expanded bool   `json:"expanded,omitempty" doc:"The term came from a synonym expansion, not the query as typed"`
+}
+
+type ScoreComponent struct {
+	Original   float64 `json:"original"`
+	Normalized float64 `json:"normalized"`
+}
+
+type SearchExplanation struct {
+	MatchedTerms    []string                  `json:"matched_terms" doc:"Terms of the query as typed found in the card"`
+	Highlights      []SearchHighlight         `json:"highlights"`
+	Filters         []string                  `json:"filters" doc:"Filters the card passed"`
+	Distance        *float64                  `json:"distance,omitempty" doc:"Vector distance, for semantic search"`
+	Score           *float64                  `json:"score,omitempty" doc:"Fused or BM25 score, for hybrid and keyword search"`
+	ScoreComponents map[string]ScoreComponent `json:"score_components,omitempty" doc:"Hybrid score per result set, keyed keyword and vector"`
+}
+
+// queryTerms splits a query into lower-cased words worth highlighting.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		word = strings.Trim(word, "'")
+		if len(word) < 2 || queryStopwords[word] || slices.Contains(terms, word) {
+			continue
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// stem strips the common English suffixes so "fly" matches "flying",
+// "creature" matches "creatures" and "sacrifice" matches "sacrificed". It only
+// needs to be good enough to line query words up with oracle wording.
+func stem(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) >= 5:
+		// "abilities", "copies" and "flies" keep their y
+		word = strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ing") && len(word) >= 6:
+		word = strings.TrimSuffix(word, "ing")
+	case strings.HasSuffix(word, "ed") && len(word) >= 5:
+		word = strings.TrimSuffix(word, "ed")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && len(word) >= 4:
+		word = strings.TrimSuffix(word, "s")
+	}
+	// "sacrifice" and "sacrific(ed)", "exile" and "exil(ing)" share a stem
+	if strings.HasSuffix(word, "e") && len(word) >= 4 {
+		word = strings.TrimSuffix(word, "e")
+	}
+	return word
+}
+
+// highlightTerms finds every word in text whose stem matches one of terms.
+func highlightTerms(field string, text string, terms []string) []SearchHighlight {
+	stems := make(map[string]string, len(terms))
+	for _, term := range terms {
+		stems[stem(term)] = term
+	}
+
+	var highlights []SearchHighlight
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !unicode.IsLetter(runes[start]) && !unicode.IsDigit(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+			end++
+		}
+		word := strings.ToLower(string(runes[start:end]))
+		if term, ok := stems[stem(word)]; ok {
+			highlights = append(highlights, SearchHighlight{Field: field, Term: term, Start: start, End: end})
+		}
+		start = end
+	}
+	return highlights
+}
+
+func parseScore(value any) *float64 {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil
+		}
+		f = parsed
+	default:
+		return nil
+	}
+	return &f
+}
+
+func parseScoreComponents(explainScore string) map[string]ScoreComponent {
+	components := map[string]ScoreComponent{}
+	for _, match := range explainScorePattern.FindAllStringSubmatch(explainScore, -1) {
+		original, _ := strconv.ParseFloat(match[2], 64)
+		normalized, _ := strconv.ParseFloat(match[3], 64)
+		components[match[1]] = ScoreComponent{Original: original, Normalized: normalized}
+	}
+	if len(components) == 0 {
+		return nil
+	}
+	return components
+}
+
+// explainTerms returns the terms of the query as typed (after spelling
+// correction) and the ones only synonym expansion added.
+func explainTerms(request MTGuruSearchRequest) (typed []string, expanded []string) {
+	if request.typed == "" {
+		return queryTerms(request.Query), nil
+	}
+	typed = queryTerms(request.typed)
+	stems := make(map[string]bool, len(typed))
+	for _, term := range typed {
+		stems[stem(term)] = true
+	}
+	for _, term := range queryTerms(request.Query) {
+		if !stems[stem(term)] {
+			expanded = append(expanded, term)
+		}
+	}
+	return typed, expanded
+}
+
+// explainResults attaches an "explanation" object to each hit in place.
+// Highlights are made against the query as typed; matches of expansion terms
+// are marked expanded and left out of matched_terms.
+func explainResults(request MTGuruSearchRequest, cards []map[string]any, appliedFilters []string) {
+	terms, expandedTerms := explainTerms(request)
+
+	for _, card := range cards {
+		explanation := SearchExplanation{
+			MatchedTerms: []string{},
+			Highlights:   []SearchHighlight{},
+			Filters:      appliedFilters,
+		}
+
+		for _, field := range explainedFields {
+			text, _ := card[field].(string)
+			explanation.Highlights = append(explanation.Highlights, highlightTerms(field, text, terms)...)
+			for _, highlight := range highlightTerms(field, text, expandedTerms) {
+				highlight.Expanded = true
+				explanation.Highlights = append(explanation.Highlights, highlight)
+			}
+		}
+		for _, highlight := range explanation.Highlights {
+			if !highlight.Expanded && !slices.Contains(explanation.MatchedTerms, highlight.Term) {
+				explanation.MatchedTerms = append(explanation.MatchedTerms, highlight.Term)
+			}
+		}
+		sort.Strings(explanation.MatchedTerms)
+
+		if additional, ok := card["_additional"].(map[string]any); ok {
+			explanation.Distance = parseScore(additional["distance"])
+			explanation.Score = parseScore(additional["score"])
+			if explainScore, ok := additional["explainScore"].(string); ok {
+				explanation.ScoreComponents = parseScoreComponents(explainScore)
+			}
+		}
+
+		card["explanation"] = explanation
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	pairs := []struct {
+		a, b string
+	}{
+		{"creature", "creatures"},
+		{"destroy", "destroys"},
+		{"destroy", "destroyed"},
+		{"sacrifice", "sacrificed"},
+		{"sacrifice", "sacrifices"},
+		{"fly", "flying"},
+		{"fly", "flies"},
+		{"exile", "exiled"},
+		{"exile", "exiling"},
+		{"ability", "abilities"},
+		{"copy", "copies"},
+		{"draw", "draws"},
+		{"enter", "enters"},
+		{"attack", "attacking"},
+		{"token", "tokens"},
+		{"die", "dies"},
+	}
+	for _, pair := range pairs {
+		if a, b := stem(pair.a), stem(pair.b); a != b {
+			t.Errorf("stem(%q) = %q, stem(%q) = %q; want equal", pair.a, a, pair.b, b)
+		}
+	}
+
+	distinct := []struct {
+		a, b string
+	}{
+		{"draw", "drawn"},
+		{"lose", "loss"},
+		{"creature", "create"},
+	}
+	for _, pair := range distinct {
+		if a, b := stem(pair.a), stem(pair.b); a == b {
+			t.Errorf("stem(%q) = stem(%q) = %q; want different stems", pair.a, pair.b, a)
+		}
+	}
+}
+
+func TestExplainResultsHighlightsTypedQuery(t *testing.T) {
+	request := MTGuruSearchRequest{Query: "flyer creature with flying", typed: "flyer"}
+	card := map[string]any{"name": "Wind Drake", "type_line": "Creature — Drake", "oracle_text": "Flying"}
+	explainResults(request, []map[string]any{card}, nil)
+
+	explanation := card["explanation"].(SearchExplanation)
+	if len(explanation.MatchedTerms) != 0 {
+		t.Errorf("matched terms = %v, want none of the expansion terms", explanation.MatchedTerms)
+	}
+	if len(explanation.Highlights) != 2 {
+		t.Fatalf("highlights = %+v, want creature and flying", explanation.Highlights)
+	}
+	for _, highlight := range explanation.Highlights {
+		if !highlight.Expanded {
+			t.Errorf("highlight %+v not marked expanded", highlight)
+		}
+	}
+
+	request = MTGuruSearchRequest{Query: "flying", typed: "flying"}
+	card = map[string]any{"oracle_text": "Flying"}
+	explainResults(request, []map[string]any{card}, nil)
+	explanation = card["explanation"].(SearchExplanation)
+	if len(explanation.Highlights) != 1 || explanation.Highlights[0].Expanded || explanation.MatchedTerms[0] != "flying" {
+		t.Errorf("explanation = %+v, want one typed match of flying", explanation)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type Favorite struct {
+	CardID    string    `json:"card_id"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Card is a snapshot taken when the favorite was added
+	Card CardRecord `json:"card"`
+}
+
+type MTGuruFavoriteRequest struct {
+	CardID string `json:"card_id" openapi:"required,minLength=36,maxLength=36" doc:"Object id of the card, as returned in _additional.id; ingestion uses the Scryfall id"`
+	Note   string `json:"note" openapi:"maxLength=1000"`
+}
+
+type MTGuruFavoriteList struct {
+	Favorites []Favorite `json:"favorites"`
+}
+
+func listFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	response := MTGuruFavoriteList{Favorites: []Favorite{}}
+	err := listRecords(userID, favoritesBucket, func(favorite Favorite) {
+		response.Favorites = append(response.Favorites, favorite)
+	})
+	if err != nil {
+		writeErrorFrom(w, r, err)
+		return
+	}
+	slices.SortStableFunc(response.Favorites, func(a, b Favorite) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// addFavoriteHandler adds a card to the user's favorites, or updates the
+// note if it is already one.
+func addFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestBody MTGuruFavoriteRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	var favorite Favorite
+	if err := getRecord(userID, favoritesBucket, requestBody.CardID, &favorite); err == nil {
+		favorite.Note = requestBody.Note
+	} else {
+		if !uuidPattern.MatchString(requestBody.CardID) {
+			writeValidationErrors(w, r, []fieldError{{Field: "card_id", Message: "must be a card object id"}})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+		defer cancel()
+
+		card, err := fetchCard(ctx, requestBody.CardID)
+		if err != nil {
+			writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+			return
+		}
+		if card == nil {
+			writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No card with id " + requestBody.CardID})
+			return
+		}
+		favorite = Favorite{
+			CardID:    requestBody.CardID,
+			Note:      requestBody.Note,
+			CreatedAt: time.Now().UTC(),
+			Card:      *card,
+		}
+	}
+
+	if err := putRecord(userID, favoritesBucket, favorite.CardID, favorite); err != nil {
+		writeErrorFrom(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, favorite)
+}
+
+func deleteFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var favorite Favorite
+	if err := getRecord(userID, favoritesBucket, r.PathValue("card_id"), &favorite); err != nil {
+		writeStoreError(w, r, err, "Card "+r.PathValue("card_id")+" is not a favorite")
+		return
+	}
+	if err := deleteRecord(userID, favoritesBucket, favorite.CardID); err != nil {
+		writeStoreError(w, r, err, "Card "+favorite.CardID+" is not a favorite")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, favorite)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"mtguru/packages/config"
+	"mtguru/packages/imagecache"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultImagesDir    = "images"
+	defaultImagesMaxMB  = 1024
+	defaultImageMaxMB   = 10
+	defaultImageTimeout = 15 * time.Second
+	imageCacheControl   = "public, max-age=604800"
+	imageCacheHeader    = "X-Cache"
+	imageCacheHit       = "HIT"
+	imageCacheMiss      = "MISS"
+)
+
+// imageCache is nil when the image service is disabled.
+var imageCache *imagecache.Cache
+
+// imageSource fills cache misses; tests and offline setups can swap it.
+var imageSource imagecache.Source
+
+// imageFetches shares one upstream fetch between concurrent misses.
+var imageFetches = struct {
+	sync.Mutex
+	inflight map[string]*imageFetch
+}{inflight: map[string]*imageFetch{}}
+
+type imageFetch struct {
+	done  chan struct{}
+	entry imagecache.Entry
+	data  []byte
+	err   error
+}
+
+func openImageCache(conf config.ImagesConfig) (*imagecache.Cache, error) {
+	if conf.DISABLED {
+		return nil, nil
+	}
+	dir := conf.DIR
+	if dir == "" {
+		dir = defaultImagesDir
+	}
+	maxMB := conf.MAX_MB
+	if maxMB <= 0 {
+		maxMB = defaultImagesMaxMB
+	}
+	cache, err := imagecache.Open(dir, int64(maxMB)<<20)
+	if err != nil {
+		return nil, err
+	}
+	images, size := cache.Stats()
+	slog.Info("Image cache opened", "dir", dir, "images", images, "bytes", size)
+	return cache, nil
+}
+
+func createImageSource(conf config.ImagesConfig) imagecache.Source {
+	upstream := conf.UPSTREAM
+	if upstream == "" {
+		upstream = imagecache.DefaultUpstream
+	}
+	maxMB := conf.MAX_IMAGE_MB
+	if maxMB <= 0 {
+		maxMB = defaultImageMaxMB
+	}
+	return &imagecache.HTTPSource{
+		BaseURL:    upstream,
+		HTTPClient: &http.Client{Timeout: imageTimeout(conf)},
+		MaxBytes:   int64(maxMB) << 20,
+	}
+}
+
+func imageTimeout(conf config.ImagesConfig) time.Duration {
+	if conf.TIMEOUT_MS > 0 {
+		return time.Duration(conf.TIMEOUT_MS) * time.Millisecond
+	}
+	return defaultImageTimeout
+}
+
+// fetchImage fills a miss from the upstream and stores it. Concurrent misses
+// for the same key share one fetch. The fetch is not tied to any one request
+// and has its own timeout, so a client that gives up only stops its own wait
+// and the others still get the image.
+func fetchImage(ctx context.Context, key string, scryfallID string, size string) (imagecache.Entry, []byte, error) {
+	imageFetches.Lock()
+	fetch, ok := imageFetches.inflight[key]
+	if !ok {
+		fetch = &imageFetch{done: make(chan struct{})}
+		imageFetches.inflight[key] = fetch
+
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), imageTimeout(activeConfig.IMAGES))
+		go func() {
+			defer cancel()
+			fetch.entry, fetch.data, fetch.err = fetchAndStoreImage(fetchCtx, key, scryfallID, size)
+
+			imageFetches.Lock()
+			delete(imageFetches.inflight, key)
+			imageFetches.Unlock()
+			close(fetch.done)
+		}()
+	}
+	imageFetches.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.entry, fetch.data, fetch.err
+	case <-ctx.Done():
+		return imagecache.Entry{}, nil, ctx.Err()
+	}
+}
+
+func fetchAndStoreImage(ctx context.Context, key string, scryfallID string, size string) (imagecache.Entry, []byte, error) {
+	data, contentType, err := imageSource.Fetch(ctx, scryfallID, size)
+	if err != nil {
+		return imagecache.Entry{}, nil, err
+	}
+	entry, err := imageCache.Put(key, data, contentType)
+	if err != nil {
+		// The image can still be served, just not from disk next time
+		slog.WarnContext(ctx, "Could not cache image", "key", key, "error", err.Error())
+		entry = imagecache.Entry{Key: key, ContentType: contentType, Size: int64(len(data)), Stored: time.Now().UTC()}
+	}
+	return entry, data, nil
+}
+
+func writeImageHeaders(w http.ResponseWriter, entry imagecache.Entry, cacheStatus string) {
+	w.Header().Set("Content-Type", entry.ContentType)
+	w.Header().Set("Cache-Control", imageCacheControl)
+	if entry.Hash != "" {
+		// Content-addressed, so the hash is a strong validator
+		w.Header().Set("ETag", `"`+entry.Hash+`"`)
+	}
+	w.Header().Set(imageCacheHeader, cacheStatus)
+}
+
+func imageHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, size := r.PathValue("scryfall_id"), r.PathValue("size")
+	if imageCache == nil {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "The image service is disabled"})
+		return
+	}
+	if _, ok := imagecache.Sizes[size]; !ok || !uuidPattern.MatchString(id) {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No image " + id + "/" + size})
+		return
+	}
+	key := id + "-" + size
+
+	if entry, path, ok := imageCache.Get(key); ok {
+		file, err := os.Open(path)
+		if err == nil {
+			defer file.Close()
+			writeImageHeaders(w, entry, imageCacheHit)
+			http.ServeContent(w, r, "", entry.Stored, file)
+			return
+		}
+		// Evicted between Get and Open
+	}
+
+	entry, data, err := fetchImage(r.Context(), key, id, size)
+	switch {
+	case errors.Is(err, imagecache.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No image " + id + "/" + size})
+		return
+	case errors.Is(err, imagecache.ErrTooLarge):
+		writeErrorFrom(w, r, newHTTPError(http.StatusBadGateway, errCodeUpstreamFailed, "The upstream image is larger than the configured limit", err))
+		return
+	case r.Context().Err() != nil:
+		writeErrorFrom(w, r, newHTTPError(statusClientClosedRequest, errCodeClientClosed, "Client closed request", err))
+		return
+	case err != nil:
+		writeErrorFrom(w, r, newHTTPError(http.StatusBadGateway, errCodeUpstreamFailed, "Could not fetch the image upstream", err))
+		return
+	}
+
+	writeImageHeaders(w, entry, imageCacheMiss)
+	http.ServeContent(w, r, "", entry.Stored, bytes.NewReader(data))
+}
@@ -2,14 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
+	"mtguru/packages/cardquery"
 	"mtguru/packages/config"
 	"mtguru/packages/custom_logger"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
-	"github.com/rs/cors"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
@@ -17,17 +19,56 @@ import (
 )
 
 type MTGuruSearchRequestFilters struct {
-	SetType string `json:"set_type"`
-	Color   string `json:"colors"`
-	Rarity  string `json:"rarity"`
+	SetType string `json:"set_type" doc:"Only cards from sets of this type (see /api/sets)"`
+	Set     string `json:"set" doc:"Only cards printed in this set, by set code"`
+	// Artist can also be given as artist:"name" in the query
+	Artist string `json:"artist" doc:"Only cards whose artist contains these words"`
+	// colorless isn't offered: Weaviate can't match an empty colors array
+	Color  string `json:"colors" openapi:"enum=|white|blue|black|red|green" doc:"Only cards of this colour"`
+	Rarity string `json:"rarity" openapi:"enum=|common|uncommon|rare|mythic|special|bonus"`
 }
 
 type MTGuruSearchRequest struct {
-	Query   string                     `json:"query"`
+	Query   string                     `json:"query" openapi:"required,minLength=1,maxLength=500" doc:"Natural language description of the cards to find"`
 	Filters MTGuruSearchRequestFilters `json:"filters"`
+	Limit   int                        `json:"limit" openapi:"minimum=0,maximum=200" doc:"Maximum number of cards to return, 0 uses the default"`
+	Mode    string                     `json:"mode" openapi:"enum=|semantic|hybrid|keyword" doc:"semantic (default) uses nearText, keyword uses BM25, hybrid fuses both"`
+	Explain bool                       `json:"explain" doc:"Attach an explanation (matched terms, highlights, filters, score components) to every hit"`
+	// NoExpansion searches the query exactly as written
+	NoExpansion bool `json:"no_expansion" doc:"Skip MTG jargon and synonym expansion"`
+	// NoCorrection opts out of "did you mean" spelling correction
+	NoCorrection bool            `json:"no_correction" doc:"Search the query without spelling correction"`
+	Rerank       string          `json:"rerank" openapi:"enum=|none|heuristic|cross_encoder" doc:"Reranker applied after retrieval, empty uses the server default"`
+	Candidates   int             `json:"candidates" openapi:"minimum=0,maximum=200" doc:"Hits retrieved for reranking and diversification, 0 uses the server default; never fewer than limit"`
+	Diversify    SearchDiversify `json:"diversify"`
+	// Relevance cutoffs, semantic search only
+	MaxDistance  float64 `json:"max_distance" openapi:"minimum=0,maximum=2" doc:"Drop hits further than this vector distance, 0 for no limit"`
+	MinCertainty float64 `json:"min_certainty" openapi:"minimum=0,maximum=1" doc:"Drop hits below this certainty (1 - distance/2), 0 for no limit"`
+	AutoCutoff   bool    `json:"auto_cutoff" doc:"Drop hits after the sharpest jump in distance"`
 	// Filters map[string]string `json:"filters"`
+
+	// typed is the query before synonym expansion, set by rewriteQuery, so
+	// explanations can tell the words asked for from the expansion terms
+	typed string
 }
 
+const defaultSearchTimeout = 10 * time.Second
+const defaultSearchLimit = 29
+
+const (
+	searchModeSemantic = "semantic"
+	searchModeHybrid   = "hybrid"
+	searchModeKeyword  = "keyword"
+)
+
+// keywordSearchProperties are the BM25 fields for keyword and hybrid search,
+// with card names weighted double.
+var keywordSearchProperties = []string{"name^2", "type_line", "oracle_text"}
+
+// statusClientClosedRequest is the non-standard status (popularised by nginx)
+// recorded when the client goes away before we could answer.
+const statusClientClosedRequest = 499
+
 var activeConfig config.EnvironmentConfig
 var client *weaviate.Client
 
@@ -36,6 +77,10 @@ func init() {
 	custom_logger.CreateLogger()
 	activeConfig = config.CreateConfig()
 	client = createClient(activeConfig)
+	answerer = createLLM(activeConfig)
+	synonymDictionary = loadSynonyms(activeConfig)
+	createRerankers(activeConfig.RERANK)
+	imageSource = createImageSource(activeConfig.IMAGES)
 }
 
 func createClient(conf config.EnvironmentConfig) *weaviate.Client {
@@ -64,122 +109,199 @@ func createClient(conf config.EnvironmentConfig) *weaviate.Client {
 
 }
 
-func searchDatabase(search_string string, search_filters MTGuruSearchRequestFilters) *models.GraphQLResponse {
+func searchTimeout(conf config.EnvironmentConfig) time.Duration {
+	if conf.SEARCH_TIMEOUT_MS <= 0 {
+		return defaultSearchTimeout
+	}
+	return time.Duration(conf.SEARCH_TIMEOUT_MS) * time.Millisecond
+}
+
+func searchLimit(limit int) int {
+	if limit <= 0 {
+		return defaultSearchLimit
+	}
+	return limit
+}
+
+func searchDatabase(ctx context.Context, request MTGuruSearchRequest, limit int, offset int) (*models.GraphQLResponse, error) {
 
 	// search_string := "make my units fly"
+	search_string := request.Query
+	search_filters := request.Filters
+
+	slog.InfoContext(ctx, fmt.Sprintf("SetType: %v", search_filters.SetType))
+	slog.InfoContext(ctx, fmt.Sprintf("Color: %v", search_filters.Color))
+	slog.InfoContext(ctx, fmt.Sprintf("Rarity: %v", search_filters.Rarity))
+
+	operands := []*filters.WhereBuilder{
+		filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.NotEqual).
+			WithValueString("token"),
+		filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.NotEqual).
+			WithValueString("memorabilia"),
+	}
+	// appliedFilters describes the operands of where for search explanations
+	appliedFilters := []string{"set_type != token", "set_type != memorabilia"}
+
+	if search_filters.SetType != "" {
+		operands = append(operands, filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.Equal).
+			WithValueString(search_filters.SetType))
+		appliedFilters = append(appliedFilters, "set_type = "+search_filters.SetType)
+	}
+	if search_filters.Set != "" {
+		operands = append(operands, filters.Where().
+			WithPath([]string{"set"}).
+			WithOperator(filters.Equal).
+			WithValueString(strings.ToLower(search_filters.Set)))
+		appliedFilters = append(appliedFilters, "set = "+strings.ToLower(search_filters.Set))
+	}
+	if search_filters.Artist != "" {
+		operands = append(operands, cardQueryWhere([]cardquery.Term{{Field: cardquery.FieldArtist, Operator: cardquery.OpContains, Value: strings.ToLower(search_filters.Artist)}})...)
+		appliedFilters = append(appliedFilters, "artist ~ "+search_filters.Artist)
+	}
+	if search_filters.Color != "" {
+		operands = append(operands, filters.Where().
+			WithPath([]string{"colors"}).
+			WithOperator(filters.ContainsAny).
+			WithValueString(filterColorLetters[search_filters.Color]))
+		appliedFilters = append(appliedFilters, "colors contains "+filterColorLetters[search_filters.Color])
+	}
+	if search_filters.Rarity != "" {
+		operands = append(operands, filters.Where().
+			WithPath([]string{"rarity"}).
+			WithOperator(filters.Equal).
+			WithValueString(search_filters.Rarity))
+		appliedFilters = append(appliedFilters, "rarity = "+search_filters.Rarity)
+	}
 
-	slog.Info(fmt.Sprintf("SetType: %v", search_filters.SetType))
-	slog.Info(fmt.Sprintf("Color: %v", search_filters.Color))
-	slog.Info(fmt.Sprintf("Rarity: %v", search_filters.Rarity))
-
-	ctx := context.Background()
-
-	where := filters.Where().
-		WithOperator(filters.And).
-		WithOperands([]*filters.WhereBuilder{
-			filters.Where().
-				WithPath([]string{"set_type"}).
-				WithOperator(filters.NotEqual).
-				WithValueString("token"),
-			filters.Where().
-				WithPath([]string{"set_type"}).
-				WithOperator(filters.NotEqual).
-				WithValueString("memorabilia"),
-		})
-
-	// if search_filters.SetType != "" {
-	// 	where = where.WithOperands([]*filters.WhereBuilder{
-	// 		filters.Where().
-	// 			WithPath([]string{"set_type"}).
-	// 			WithOperator(filters.Equal).
-	// 			WithValueString(search_filters.SetType),
-	// 	})
-	// }
-	slog.Debug(string(search_filters.Color[0]))
-	// if search_filters.Color != "" {
-	// 	string_color := string(search_filters.Color[0])
-	// 	where = where.WithOperands([]*filters.WhereBuilder{
-	// 		filters.Where().
-	// 			WithPath([]string{"colors"}).
-	// 			WithOperator(filters.ContainsAny).
-	// 			WithValueString(strings.ToUpper(string_color)),
-	// 	})
-	// }
-
-	// if search_filters.Rarity != "" {
-	// 	where = where.WithOperands([]*filters.WhereBuilder{
-	// 		filters.Where().
-	// 			WithPath([]string{"rarity"}).
-	// 			WithOperator(filters.Equal).
-	// 			WithValueString(search_filters.Rarity),
-	// 	})
-	// }
-
-	response, err := client.GraphQL().Get().
+	where := filters.Where().WithOperator(filters.And).WithOperands(operands)
+
+	additional := []graphql.Field{{Name: "id"}, {Name: "distance"}}
+	if request.Mode == searchModeHybrid || request.Mode == searchModeKeyword {
+		additional = []graphql.Field{{Name: "id"}, {Name: "score"}, {Name: "explainScore"}}
+	}
+	if request.Diversify.Enabled {
+		// MMR compares hits by their stored embeddings
+		additional = append(additional, graphql.Field{Name: "vector"})
+	}
+
+	get := client.GraphQL().Get().
 		WithClassName("Mtguru").
 		// WithFields is used to specify the fields you want to retrieve from the cards matched in the json resposne
-		WithFields(
-			graphql.Field{Name: "name"},
-			// graphql.Field{Name: "mana_cost"},
-			// graphql.Field{Name: "type_line"},
-			graphql.Field{Name: "oracle_text"},
-			// graphql.Field{Name: "power"},
-			// graphql.Field{Name: "toughness"},
-			// graphql.Field{Name: "loyalty"},
-			graphql.Field{Name: "colors"},
-			graphql.Field{Name: "set_name"},
-			// graphql.Field{Name: "keywords"},
-			// graphql.Field{Name: "flavor_text"},
-			// graphql.Field{Name: "rarity"},
-			graphql.Field{Name: "set_type"},
-			graphql.Field{Name: "scryfall_uri"},
-			graphql.Field{Name: "image_uris", Fields: []graphql.Field{
+		WithFields(presentFields([]graphql.Field{
+			{Name: "name"},
+			{Name: "scryfall_id"},
+			{Name: "oracle_id"},
+			{Name: "mana_cost"},
+			{Name: "type_line"},
+			{Name: "oracle_text"},
+			// {Name: "power"},
+			// {Name: "toughness"},
+			// {Name: "loyalty"},
+			{Name: "colors"},
+			{Name: "set_name"},
+			// {Name: "keywords"},
+			// {Name: "flavor_text"},
+			// {Name: "rarity"},
+			{Name: "set_type"},
+			{Name: "scryfall_uri"},
+			{Name: "image_uris", Fields: []graphql.Field{
 				{Name: "normal"},
 				{Name: "large"},
 			}},
-			graphql.Field{Name: "_additional", Fields: []graphql.Field{
-				{Name: "distance"}}},
-		).
-		WithNearText(client.GraphQL().NearTextArgBuilder().
-			WithConcepts([]string{search_string})).
-		WithLimit(29).
+			{Name: "layout"},
+			cardFacesField,
+			{Name: "_additional", Fields: additional},
+		})...)
+
+	switch request.Mode {
+	case searchModeKeyword:
+		get = get.WithBM25(client.GraphQL().Bm25ArgBuilder().
+			WithQuery(search_string).
+			WithProperties(keywordSearchProperties...))
+	case searchModeHybrid:
+		get = get.WithHybrid(client.GraphQL().HybridArgumentBuilder().
+			WithQuery(search_string).
+			WithProperties(keywordSearchProperties))
+	default:
+		get = get.WithNearText(client.GraphQL().NearTextArgBuilder().
+			WithConcepts([]string{search_string}))
+	}
+
+	response, err := get.
+		WithLimit(limit).
+		WithOffset(offset).
 		WithWhere(where).
 		Do(ctx)
 
 	if err != nil {
-		slog.Debug(err.Error())
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Prompt:", "prompt", search_string)
+	slog.DebugContext(ctx, "Response:", "matches", response)
+
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+	if request.Explain {
+		explainResults(request, resultCards(response), appliedFilters)
 	}
+	return response, nil
+}
 
-	slog.Info("Prompt:", "prompt", search_string)
-	slog.Debug("Response:", "matches", response)
+// resultCards pulls the list of matched cards out of a Get.Mtguru response.
+func resultCards(response *models.GraphQLResponse) []map[string]any {
+	return resultObjects(response, "Mtguru")
+}
 
-	return response
+// resultObjects returns the objects of one class from a GraphQL Get response.
+func resultObjects(response *models.GraphQLResponse, className string) []map[string]any {
+	if response == nil {
+		return nil
+	}
+	get, _ := response.Data["Get"].(map[string]any)
+	matches, _ := get[className].([]any)
+
+	cards := make([]map[string]any, 0, len(matches))
+	for _, match := range matches {
+		if card, ok := match.(map[string]any); ok {
+			cards = append(cards, card)
+		}
+	}
+	return cards
 }
 
 func searchHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody MTGuruSearchRequest
-	err := json.NewDecoder(r.Body).Decode(&requestBody)
-	if err != nil {
-		slog.Debug("Error decoding request body", "error", err.Error())
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
 		return
 	}
 
-	slog.Info("Received search request:", "query", requestBody.Query, "filters", requestBody.Filters)
+	slog.InfoContext(r.Context(), "Received search request:", "query", requestBody.Query, "filters", requestBody.Filters)
 
-	results := searchDatabase(requestBody.Query, requestBody.Filters)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
 
-	responseJSON, err := json.Marshal(results)
+	results, err := executeSearch(ctx, requestBody, searchLimit(requestBody.Limit))
 	if err != nil {
-		slog.Debug("Error marshalling response", "error", err.Error())
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		httpErr := classifySearchError(r.Context(), ctx, err)
+		recordSearch(r.Context(), searchSourceSearch, requestBody, start, nil, httpErr.code)
+		writeErrorFrom(w, r, httpErr)
 		return
 	}
+	recordSearch(r.Context(), searchSourceSearch, requestBody, start, results.cards(), "")
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseJSON)
+	writeJSON(w, http.StatusOK, results)
 
 	// searchDatabase(requestBody.Query)
 }
@@ -188,13 +310,47 @@ func initHandler() http.Handler {
 	mux := http.NewServeMux()
 
 	// mux.HandleFunc("GET /api/health", alive)
-	mux.HandleFunc("POST /api/search", searchHandler)
-	return cors.Default().Handler(mux)
+	routes := apiRoutes()
+	for _, route := range routes {
+		mux.HandleFunc(route.Method+" "+route.Path, route.Handler)
+	}
+	mux.HandleFunc("/", unmatchedHandler(routes))
+
+	handler := recoverMiddleware(mux)
+	handler = accessLogMiddleware(handler)
+	handler = requestIDMiddleware(handler)
+	return createCORS(activeConfig.CORS).Handler(handler)
 
 }
 
 func main() {
 
+	var err error
+	store, err = openStore(storePath())
+	if err != nil {
+		slog.Error("Could not open store", "path", storePath(), "error", err.Error())
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	searchLog, err = openSearchLog(activeConfig.ANALYTICS)
+	if err != nil {
+		slog.Error("Could not open search analytics log", "error", err.Error())
+		os.Exit(1)
+	}
+	if searchLog != nil {
+		defer searchLog.Close()
+	}
+
+	imageCache, err = openImageCache(activeConfig.IMAGES)
+	if err != nil {
+		slog.Error("Could not open image cache", "error", err.Error())
+		os.Exit(1)
+	}
+
+	// Searches are served uncorrected until the vocabulary is built
+	go loadVocabulary(context.Background())
+
 	handler := initHandler()
 	slog.Info("Starting server on port 8888...")
 	http.ListenAndServe(":8888", handler)
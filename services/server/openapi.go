@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The OpenAPI document is generated from apiRoutes and the Go request and
+// response types, so it cannot drift from what the handlers actually accept.
+// Struct fields can refine their schema with tags:
+//
+//	openapi:"required,enum=a|b|c,minimum=1,maximum=100,minLength=1,maxLength=200,maxItems=50"
+//	doc:"Human readable description"
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties any                       `json:"additionalProperties,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	OperationID string                     `json:"operationId,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       map[string]string                       `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+// schemaGenerator turns Go types into OpenAPI schemas, registering every
+// named struct once under components/schemas.
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: map[string]*openAPISchema{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *openAPISchema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &openAPISchema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	default:
+		// interface{} and friends accept any JSON value
+		return &openAPISchema{}
+	}
+}
+
+func (g *schemaGenerator) ref(t reflect.Type) *openAPISchema {
+	name, ok := g.names[t]
+	if !ok {
+		// Capitalise so unexported helper types still get conventional schema names
+		name = capitalise(t.Name())
+		if _, taken := g.schemas[name]; taken {
+			// A type of the same name from another package got there first
+			name = capitalise(path.Base(t.PkgPath())) + name
+		}
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = capitalise(path.Base(t.PkgPath())) + capitalise(t.Name()) + strconv.Itoa(i)
+		}
+		g.names[t] = name
+		// Register before recursing so self-referencing types terminate
+		g.schemas[name] = &openAPISchema{}
+		*g.schemas[name] = *g.structSchema(t)
+	}
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func capitalise(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{
+		Type:                 "object",
+		Properties:           map[string]*openAPISchema{},
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := g.structSchema(field.Type)
+			for propName, prop := range embedded.Properties {
+				schema.Properties[propName] = prop
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := g.schemaFor(field.Type)
+		required := applySchemaTags(prop, field.Tag)
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// applySchemaTags copies the openapi and doc struct tags onto a property
+// schema and reports whether the field is required.
+func applySchemaTags(prop *openAPISchema, tag reflect.StructTag) bool {
+	if doc := tag.Get("doc"); doc != "" {
+		if prop.Ref != "" {
+			// $ref siblings are ignored by OpenAPI 3.0, so wrap the reference
+			*prop = openAPISchema{Description: doc, AllOf: []*openAPISchema{{Ref: prop.Ref}}}
+		} else {
+			prop.Description = doc
+		}
+	}
+
+	required := false
+	for _, option := range strings.Split(tag.Get("openapi"), ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			for _, v := range strings.Split(value, "|") {
+				prop.Enum = append(prop.Enum, v)
+			}
+		case "minimum":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				prop.Minimum = &f
+			}
+		case "maximum":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				prop.Maximum = &f
+			}
+		case "minLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				prop.MinLength = &n
+			}
+		case "maxLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				prop.MaxLength = &n
+			}
+		case "maxItems":
+			if n, err := strconv.Atoi(value); err == nil {
+				prop.MaxItems = &n
+			}
+		}
+	}
+	return required
+}
+
+type apiSpec struct {
+	document *openAPIDocument
+	json     []byte
+	gen      *schemaGenerator
+}
+
+var (
+	specOnce   sync.Once
+	activeSpec *apiSpec
+)
+
+// getAPISpec builds the document on first use. It is lazy rather than a
+// package-level initialiser because the routes refer back to handlers that
+// validate against the spec.
+func getAPISpec() *apiSpec {
+	specOnce.Do(func() {
+		activeSpec = buildAPISpec(apiRoutes())
+	})
+	return activeSpec
+}
+
+func buildAPISpec(routes []apiRoute) *apiSpec {
+	gen := newSchemaGenerator()
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: map[string]string{
+			"title":       "MTGuru API",
+			"version":     "1.0.0",
+			"description": "Natural language search over Magic: The Gathering cards.",
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+	}
+
+	for _, route := range routes {
+		op := &openAPIOperation{
+			Summary:     route.Summary,
+			OperationID: route.OperationID,
+			Parameters:  route.Params,
+			Responses:   map[string]openAPIResponse{},
+		}
+
+		if route.Request != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: gen.schemaFor(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success := openAPIResponse{Description: "Success"}
+		if route.Response != nil {
+			success.Content = map[string]openAPIMediaType{
+				contentType: {Schema: gen.schemaFor(reflect.TypeOf(route.Response))},
+			}
+		} else if route.ContentType != "" {
+			success.Content = map[string]openAPIMediaType{contentType: {}}
+		}
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		} else {
+			success.Description = http.StatusText(status)
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		errorContent := map[string]openAPIMediaType{
+			"application/json": {Schema: gen.schemaFor(reflect.TypeOf(ErrorResponse{}))},
+		}
+		if route.Request != nil {
+			op.Responses["400"] = openAPIResponse{
+				Description: "The request body failed validation",
+				Content:     errorContent,
+			}
+		}
+		op.Responses["default"] = openAPIResponse{
+			Description: "Error",
+			Content:     errorContent,
+		}
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+	doc.Components.Schemas = gen.schemas
+
+	specJSON, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		slog.Error("Error marshalling OpenAPI document", "error", err.Error())
+	}
+
+	return &apiSpec{document: doc, json: specJSON, gen: gen}
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(getAPISpec().json)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"mtguru/packages/decklist"
+	"reflect"
+	"testing"
+)
+
+// Entry shares its name with decklist.Entry.
+type Entry struct {
+	Note string `json:"note"`
+}
+
+func TestSchemaNamesDoNotCollide(t *testing.T) {
+	g := newSchemaGenerator()
+	local := g.ref(reflect.TypeOf(Entry{})).Ref
+	other := g.ref(reflect.TypeOf(decklist.Entry{})).Ref
+
+	if local == other {
+		t.Fatalf("both Entry types map to %s", local)
+	}
+	if local != "#/components/schemas/Entry" || other != "#/components/schemas/DecklistEntry" {
+		t.Errorf("refs = %s, %s; want Entry and DecklistEntry", local, other)
+	}
+	if _, ok := g.schemas["Entry"].Properties["note"]; !ok {
+		t.Errorf("Entry schema was overwritten: %+v", g.schemas["Entry"])
+	}
+}
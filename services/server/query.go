@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"mtguru/packages/cardquery"
+	"mtguru/packages/config"
+	"mtguru/packages/spelling"
+	"mtguru/packages/synonyms"
+	"strings"
+
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// QueryRewrite reports what the server changed in a query before searching.
+type QueryRewrite struct {
+	Original string `json:"original"`
+	Searched string `json:"searched" doc:"The query actually embedded and matched"`
+	// Corrected is set when spelling correction changed the query ("did you mean")
+	Corrected   string                `json:"corrected,omitempty" doc:"The query after spelling correction, when it changed"`
+	Corrections []spelling.Correction `json:"corrections"`
+	Expansions  []synonyms.Expansion  `json:"expansions" doc:"Jargon and synonym expansions applied to the query"`
+	Artist      string                `json:"artist,omitempty" doc:"Artist filter taken from an artist: term in the query"`
+}
+
+// MTGuruSearchResponse keeps the GraphQL "data" object, so hits are still at
+// data.Get.Mtguru, and adds how the query was rewritten.
+type MTGuruSearchResponse struct {
+	Data         map[string]models.JSONObject `json:"data"`
+	QueryRewrite QueryRewrite                 `json:"query_rewrite"`
+	Rerank       *RerankInfo                  `json:"rerank,omitempty"`
+	Diversify    *DiversifyInfo               `json:"diversify,omitempty"`
+	Cutoff       *CutoffInfo                  `json:"cutoff,omitempty"`
+}
+
+func (r MTGuruSearchResponse) cards() []map[string]any {
+	return resultCards(&models.GraphQLResponse{Data: r.Data})
+}
+
+var synonymDictionary *synonyms.Dictionary
+
+// loadSynonyms returns the built-in dictionary, with SYNONYMS_PATH merged
+// over it when configured. A broken file is logged and ignored.
+func loadSynonyms(conf config.EnvironmentConfig) *synonyms.Dictionary {
+	dictionary := synonyms.Default()
+	if conf.SYNONYMS_PATH != "" {
+		custom, err := synonyms.Load(conf.SYNONYMS_PATH)
+		if err != nil {
+			slog.Error("Could not load synonyms, using the built-in dictionary", "path", conf.SYNONYMS_PATH, "error", err.Error())
+		} else {
+			dictionary.Merge(custom)
+		}
+	}
+	slog.Info("Synonym dictionary loaded", "terms", dictionary.Len())
+	return dictionary
+}
+
+// rewriteQuery applies the query rewrites the request has not opted out of
+// and returns the request to search with.
+func rewriteQuery(request MTGuruSearchRequest) (MTGuruSearchRequest, QueryRewrite) {
+	rewrite := QueryRewrite{
+		Original:    request.Query,
+		Searched:    request.Query,
+		Corrections: []spelling.Correction{},
+		Expansions:  []synonyms.Expansion{},
+	}
+
+	// artist:"name" becomes a filter, and stays out of correction and embedding
+	if rest, terms, err := cardquery.Extract(rewrite.Searched, cardquery.FieldArtist); err == nil && len(terms) > 0 && strings.TrimSpace(rest) != "" {
+		artist := strings.ToLower(request.Filters.Artist)
+		for _, term := range terms {
+			artist = strings.TrimSpace(artist + " " + term.Value)
+		}
+		rewrite.Artist, rewrite.Searched = artist, rest
+		request.Filters.Artist = rewrite.Artist
+	}
+
+	// Correct first so misspelled jargon ("wrth") still expands
+	if !request.NoCorrection {
+		corrected, corrections := correctSpelling(rewrite.Searched)
+		if len(corrections) > 0 {
+			rewrite.Searched, rewrite.Corrected, rewrite.Corrections = corrected, corrected, corrections
+		}
+	}
+	request.typed = rewrite.Searched
+	if !request.NoExpansion && synonymDictionary != nil {
+		rewrite.Searched, rewrite.Expansions = synonymDictionary.Expand(rewrite.Searched)
+	}
+
+	request.Query = rewrite.Searched
+	return request, rewrite
+}
+
+// setResultCards replaces the hits of a GraphQL response.
+func setResultCards(response *models.GraphQLResponse, cards []map[string]any) {
+	matches := make([]any, len(cards))
+	for i, card := range cards {
+		matches[i] = card
+	}
+	if get, ok := response.Data["Get"].(map[string]any); ok {
+		get["Mtguru"] = matches
+	}
+}
+
+// executeSearch rewrites the query and retrieves up to limit hits. When a
+// reranker applies or diversification is asked for, a larger candidate pool
+// is retrieved, reranked and then cut down to limit by MMR. Relevance
+// cutoffs apply to the retrieved hits, before reranking.
+func executeSearch(ctx context.Context, request MTGuruSearchRequest, limit int) (MTGuruSearchResponse, error) {
+	search, rewrite := rewriteQuery(request)
+	return executeRewrittenSearch(ctx, search, rewrite, limit)
+}
+
+// executeRewrittenSearch is executeSearch for a request rewriteQuery has
+// already rewritten, for callers that report the rewrite before searching.
+func executeRewrittenSearch(ctx context.Context, search MTGuruSearchRequest, rewrite QueryRewrite, limit int) (MTGuruSearchResponse, error) {
+	plan, err := planSearch(search, rewrite, limit)
+	if err != nil {
+		return MTGuruSearchResponse{}, err
+	}
+	response, err := searchDatabase(ctx, search, plan.retrieve, 0)
+	if err != nil {
+		return MTGuruSearchResponse{}, err
+	}
+	return plan.process(ctx, response), nil
+}
+
+// searchPlan splits a rewritten search into retrieval and the processing of
+// what was retrieved, so streaming can report progress in between.
+type searchPlan struct {
+	search   MTGuruSearchRequest
+	rewrite  QueryRewrite
+	reranker Reranker
+	limit    int
+	// retrieve is limit, or the candidate pool when the hits are reordered
+	retrieve int
+}
+
+func planSearch(search MTGuruSearchRequest, rewrite QueryRewrite, limit int) (searchPlan, error) {
+	reranker, err := selectReranker(search.Rerank)
+	if err != nil {
+		return searchPlan{}, err
+	}
+	if err := checkCutoff(search); err != nil {
+		return searchPlan{}, err
+	}
+
+	plan := searchPlan{search: search, rewrite: rewrite, reranker: reranker, limit: limit, retrieve: limit}
+	if reranker != nil || search.Diversify.Enabled {
+		plan.retrieve = rerankCandidatePool(search.Candidates, limit)
+	}
+	return plan, nil
+}
+
+// processed reports whether the retrieved hits are cut, reranked or
+// diversified before they are returned.
+func (p searchPlan) processed() bool {
+	return p.reranker != nil || p.search.Diversify.Enabled || cutoffRequested(p.search)
+}
+
+// process applies the cutoff, reranker and diversification to the retrieved
+// hits.
+func (p searchPlan) process(ctx context.Context, response *models.GraphQLResponse) MTGuruSearchResponse {
+	search, limit := p.search, p.limit
+	result := MTGuruSearchResponse{Data: response.Data, QueryRewrite: p.rewrite}
+	cards := resultCards(response)
+	if cutoffRequested(search) {
+		var info CutoffInfo
+		cards, info = cutoffCards(cards, search, limit)
+		result.Cutoff = &info
+	}
+	if p.reranker != nil {
+		// Keep the whole pool for diversification to choose from
+		keep := limit
+		if search.Diversify.Enabled {
+			keep = len(cards)
+		}
+		var info RerankInfo
+		cards, info = rerankCards(ctx, p.reranker, search.Query, cards, keep)
+		result.Rerank = &info
+	}
+	if search.Diversify.Enabled {
+		var info DiversifyInfo
+		cards, info = diversifyCards(cards, search.Diversify, limit)
+		result.Diversify = &info
+	}
+	if p.processed() {
+		setResultCards(response, cards)
+	}
+	return result
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand/v2"
+	"mtguru/packages/cardquery"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// maxQueryOffset is Weaviate's default QUERY_MAXIMUM_RESULTS; offset+limit
+// past it is rejected, so larger result sets are split into mana value
+// buckets before picking by offset.
+const maxQueryOffset = 10000
+
+// randomCardAttempts bounds the rejection sampling that makes every oracle
+// card, not every printing, equally likely.
+const randomCardAttempts = 20
+
+// manaValueBuckets split the collection into ranges each well under
+// maxQueryOffset cards; the last is open-ended.
+var manaValueBuckets = []float64{0, 1, 2, 3, 4, 5, 6, 7}
+
+type MTGuruRandomCardResponse struct {
+	Card     CardRecord `json:"card"`
+	Matching int        `json:"matching" doc:"Cards matching the query and filters, counting every printing"`
+	Date     string     `json:"date,omitempty" doc:"The day a card of the day was picked for"`
+}
+
+var filterColorLetters = map[string]string{
+	"white": "W", "blue": "U", "black": "B", "red": "R", "green": "G",
+}
+
+// cardQueryTerms parses q and adds the search filter parameters
+// (colors, rarity, set_type) as further terms.
+func cardQueryTerms(r *http.Request) ([]cardquery.Term, *APIError) {
+	query := r.URL.Query()
+	terms, err := cardquery.Parse(query.Get("q"))
+	if err != nil {
+		return nil, &APIError{Code: errCodeInvalidRequest, Message: err.Error(), Field: "q"}
+	}
+
+	if color := query.Get("colors"); color != "" {
+		letter, ok := filterColorLetters[color]
+		if !ok {
+			return nil, &APIError{Code: errCodeInvalidRequest, Message: "colors must be white, blue, black, red or green", Field: "colors"}
+		}
+		terms = append(terms, cardquery.Term{Field: cardquery.FieldColors, Operator: cardquery.OpContains, Value: letter})
+	}
+	if rarity := query.Get("rarity"); rarity != "" {
+		terms = append(terms, cardquery.Term{Field: cardquery.FieldRarity, Operator: cardquery.OpEqual, Value: strings.ToLower(rarity)})
+	}
+	if setType := query.Get("set_type"); setType != "" {
+		terms = append(terms, cardquery.Term{Field: cardquery.FieldSetType, Operator: cardquery.OpEqual, Value: strings.ToLower(setType)})
+	}
+	return terms, nil
+}
+
+var cardQueryPaths = map[string]string{
+	cardquery.FieldName:      "name",
+	cardquery.FieldType:      "type_line",
+	cardquery.FieldOracle:    "oracle_text",
+	cardquery.FieldColors:    "colors",
+	cardquery.FieldRarity:    "rarity",
+	cardquery.FieldSet:       "set",
+	cardquery.FieldSetType:   "set_type",
+	cardquery.FieldManaValue: "cmc",
+	cardquery.FieldArtist:    "artist",
+}
+
+var manaValueOperators = map[string]filters.WhereOperator{
+	cardquery.OpEqual:        filters.Equal,
+	cardquery.OpLess:         filters.LessThan,
+	cardquery.OpLessEqual:    filters.LessThanEqual,
+	cardquery.OpGreater:      filters.GreaterThan,
+	cardquery.OpGreaterEqual: filters.GreaterThanEqual,
+}
+
+// cardQueryWhere turns terms into Where operands. Text terms match each of
+// their words anywhere in the property.
+func cardQueryWhere(terms []cardquery.Term) []*filters.WhereBuilder {
+	var operands []*filters.WhereBuilder
+	for _, term := range terms {
+		path := []string{cardQueryPaths[term.Field]}
+		switch term.Field {
+		case cardquery.FieldName, cardquery.FieldType, cardquery.FieldOracle, cardquery.FieldArtist:
+			for _, word := range strings.Fields(term.Value) {
+				operands = append(operands, filters.Where().
+					WithPath(path).
+					WithOperator(filters.Like).
+					WithValueText("*"+word+"*"))
+			}
+		case cardquery.FieldColors:
+			operands = append(operands, filters.Where().
+				WithPath(path).
+				WithOperator(filters.ContainsAll).
+				WithValueText(term.Colors()...))
+		case cardquery.FieldManaValue:
+			operands = append(operands, filters.Where().
+				WithPath(path).
+				WithOperator(manaValueOperators[term.Operator]).
+				WithValueNumber(term.Number()))
+		default:
+			operands = append(operands, filters.Where().
+				WithPath(path).
+				WithOperator(filters.Equal).
+				WithValueString(term.Value))
+		}
+	}
+	return operands
+}
+
+// randomCardWhere keeps out the same non-game sets as search.
+func randomCardWhere(terms []cardquery.Term, extra ...*filters.WhereBuilder) *filters.WhereBuilder {
+	operands := append([]*filters.WhereBuilder{
+		filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.NotEqual).
+			WithValueString("token"),
+		filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.NotEqual).
+			WithValueString("memorabilia"),
+	}, cardQueryWhere(terms)...)
+	return filters.Where().WithOperator(filters.And).WithOperands(append(operands, extra...))
+}
+
+// countCards returns how many objects match where.
+func countCards(ctx context.Context, where *filters.WhereBuilder) (int, error) {
+	response, err := client.GraphQL().Aggregate().
+		WithClassName("Mtguru").
+		WithFields(graphql.Field{Name: "meta", Fields: []graphql.Field{{Name: "count"}}}).
+		WithWhere(where).
+		Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := graphQLError(response); err != nil {
+		return 0, err
+	}
+	return aggregateCount(response), nil
+}
+
+func aggregateCount(response *models.GraphQLResponse) int {
+	aggregate, _ := response.Data["Aggregate"].(map[string]any)
+	groups, _ := aggregate["Mtguru"].([]any)
+	if len(groups) == 0 {
+		return 0
+	}
+	group, _ := groups[0].(map[string]any)
+	meta, _ := group["meta"].(map[string]any)
+	count, _ := meta["count"].(float64)
+	return int(count)
+}
+
+// cardAtIndex returns the index-th card matching where, out of total.
+func cardAtIndex(ctx context.Context, where *filters.WhereBuilder, terms []cardquery.Term, index int, total int) (*CardRecord, error) {
+	if total > maxQueryOffset {
+		for i, low := range manaValueBuckets {
+			bucket := []*filters.WhereBuilder{filters.Where().
+				WithPath([]string{"cmc"}).
+				WithOperator(filters.GreaterThanEqual).
+				WithValueNumber(low)}
+			if i+1 < len(manaValueBuckets) {
+				bucket = append(bucket, filters.Where().
+					WithPath([]string{"cmc"}).
+					WithOperator(filters.LessThan).
+					WithValueNumber(manaValueBuckets[i+1]))
+			}
+			bucketWhere := randomCardWhere(terms, bucket...)
+			count, err := countCards(ctx, bucketWhere)
+			if err != nil {
+				return nil, err
+			}
+			if index < count {
+				where, index = bucketWhere, min(index, maxQueryOffset-1)
+				break
+			}
+			index -= count
+		}
+	}
+
+	response, err := client.GraphQL().Get().
+		WithClassName("Mtguru").
+		WithFields(cardFields("id")...).
+		WithWhere(where).
+		WithOffset(index).
+		WithLimit(1).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+	results := resultCards(response)
+	if len(results) == 0 {
+		return nil, nil
+	}
+	card := cardRecordFromResult(results[0])
+	return &card, nil
+}
+
+// pickRandomCard draws a card matching terms using rng. A drawn printing is
+// kept with probability 1/printings so every oracle card is equally likely.
+func pickRandomCard(ctx context.Context, terms []cardquery.Term, rng *rand.Rand) (MTGuruRandomCardResponse, error) {
+	where := randomCardWhere(terms)
+	total, err := countCards(ctx, where)
+	if err != nil {
+		return MTGuruRandomCardResponse{}, err
+	}
+	if total == 0 {
+		return MTGuruRandomCardResponse{}, newHTTPError(http.StatusNotFound, errCodeNotFound, "No card matches the query", nil)
+	}
+
+	var card *CardRecord
+	for range randomCardAttempts {
+		card, err = cardAtIndex(ctx, where, terms, rng.IntN(total), total)
+		if err != nil {
+			return MTGuruRandomCardResponse{}, err
+		}
+		if card == nil || card.OracleID == "" {
+			continue
+		}
+		printings, err := countCards(ctx, randomCardWhere(terms, filters.Where().
+			WithPath([]string{"oracle_id"}).
+			WithOperator(filters.Equal).
+			WithValueString(card.OracleID)))
+		if err != nil {
+			return MTGuruRandomCardResponse{}, err
+		}
+		if printings <= 1 || rng.IntN(printings) == 0 {
+			break
+		}
+	}
+	if card == nil {
+		return MTGuruRandomCardResponse{}, newHTTPError(http.StatusNotFound, errCodeNotFound, "No card matches the query", nil)
+	}
+	return MTGuruRandomCardResponse{Card: *card, Matching: total}, nil
+}
+
+func randomCardHandler(w http.ResponseWriter, r *http.Request) {
+
+	terms, apiErr := cardQueryTerms(r)
+	if apiErr != nil {
+		writeError(w, r, http.StatusBadRequest, *apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	response, err := pickRandomCard(ctx, terms, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// dailySeed derives the card of the day's seed from the date and query, so
+// everyone asking on the same day with the same query gets the same card.
+func dailySeed(date string, query string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(date + "\x00" + query))
+	return h.Sum64()
+}
+
+func dailyCardHandler(w http.ResponseWriter, r *http.Request) {
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().UTC().Format(time.DateOnly)
+	} else if _, err := time.Parse(time.DateOnly, date); err != nil {
+		writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "date must be YYYY-MM-DD", Field: "date"})
+		return
+	}
+	terms, apiErr := cardQueryTerms(r)
+	if apiErr != nil {
+		writeError(w, r, http.StatusBadRequest, *apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	query := r.URL.Query()
+	query.Del("date")
+	seed := dailySeed(date, query.Encode())
+	response, err := pickRandomCard(ctx, terms, rand.New(rand.NewPCG(seed, seed)))
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+	response.Date = date
+	// The pick only changes when the day does
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"mtguru/packages/decklist"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+)
+
+const (
+	defaultRecommendationLimit = 20
+	maxClusters                = 8
+	kMeansIterations           = 20
+	closestDeckCardsPerResult  = 3
+	// maxRecommendationScan bounds how far past the nearest neighbours of a
+	// centroid the search pages looking for cards within the colour identity
+	maxRecommendationScan = 1000
+)
+
+type MTGuruDeckRecommendRequest struct {
+	Decklist string `json:"decklist" openapi:"required,minLength=1,maxLength=50000" doc:"Decklist in any format accepted by /api/decks/analyze"`
+	Format   string `json:"format" openapi:"enum=|standard|future|historic|timeless|gladiator|pioneer|explorer|modern|legacy|pauper|vintage|penny|commander|oathbreaker|standardbrawl|brawl|alchemy|paupercommander|duel|oldschool|premodern|predh" doc:"Only recommend cards legal in this format, one of Scryfall's legality keys"`
+	Limit    int    `json:"limit" openapi:"minimum=0,maximum=100" doc:"Number of recommendations, 0 uses the default"`
+	Clusters int    `json:"clusters" openapi:"minimum=0,maximum=8" doc:"Number of deck clusters to search around, 0 picks one from the deck size"`
+}
+
+type DeckCardSimilarity struct {
+	Name       string  `json:"name"`
+	Similarity float64 `json:"similarity"`
+}
+
+type DeckRecommendation struct {
+	Card             CardRecord           `json:"card"`
+	Distance         float64              `json:"distance"`
+	Cluster          int                  `json:"cluster" doc:"Cluster whose centroid found the card, -1 for the whole deck centroid"`
+	ClosestDeckCards []DeckCardSimilarity `json:"closest_deck_cards" doc:"The deck cards most similar to the recommendation, as its explanation"`
+}
+
+type DeckCluster struct {
+	Index int      `json:"index"`
+	Cards []string `json:"cards"`
+}
+
+type MTGuruDeckRecommendResponse struct {
+	ColorIdentity   []string             `json:"color_identity"`
+	Format          string               `json:"format,omitempty"`
+	Clusters        []DeckCluster        `json:"clusters"`
+	Recommendations []DeckRecommendation `json:"recommendations"`
+	Unresolved      []UnresolvedCard     `json:"unresolved"`
+}
+
+// deckVectorCard is a resolved deck card that contributes to the centroids.
+type deckVectorCard struct {
+	card   CardRecord
+	vector []float32
+}
+
+// deckColorIdentity is the commander's identity when the deck has one and
+// otherwise the union of the main deck's identities.
+func deckColorIdentity(entries []DeckEntry) []string {
+	section := decklist.SectionMain
+	for _, entry := range entries {
+		if entry.Card != nil && entry.Section == decklist.SectionCommander {
+			section = decklist.SectionCommander
+		}
+	}
+
+	colors := []string{}
+	for _, entry := range entries {
+		if entry.Card == nil || entry.Section != section {
+			continue
+		}
+		for _, color := range entry.Card.ColorIdentity {
+			if !slices.Contains(colors, color) {
+				colors = append(colors, color)
+			}
+		}
+	}
+	sort.Strings(colors)
+	return colors
+}
+
+func withinColorIdentity(card CardRecord, identity []string) bool {
+	for _, color := range card.ColorIdentity {
+		if !slices.Contains(identity, color) {
+			return false
+		}
+	}
+	return true
+}
+
+func autoClusterCount(cards int) int {
+	return max(1, min(cards/10, 4))
+}
+
+// recommendationWhere keeps out the same non-game sets as search and,
+// if a format is given, anything not legal in it.
+func recommendationWhere(format string) *filters.WhereBuilder {
+	operands := []*filters.WhereBuilder{
+		filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.NotEqual).
+			WithValueString("token"),
+		filters.Where().
+			WithPath([]string{"set_type"}).
+			WithOperator(filters.NotEqual).
+			WithValueString("memorabilia"),
+	}
+	if format != "" {
+		operands = append(operands, filters.Where().
+			WithPath([]string{"legal_formats"}).
+			WithOperator(filters.ContainsAny).
+			WithValueString(format))
+	}
+	return filters.Where().WithOperator(filters.And).WithOperands(operands)
+}
+
+type vectorHit struct {
+	card     CardRecord
+	distance float64
+	vector   []float32
+}
+
+func searchNearVector(ctx context.Context, vector []float32, where *filters.WhereBuilder, limit int, offset int) ([]vectorHit, error) {
+	response, err := client.GraphQL().Get().
+		WithClassName("Mtguru").
+		WithFields(cardFields("id", "distance", "vector")...).
+		WithNearVector(client.GraphQL().NearVectorArgBuilder().WithVector(vector)).
+		WithWhere(where).
+		WithLimit(limit).
+		WithOffset(offset).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+
+	var hits []vectorHit
+	for _, result := range resultCards(response) {
+		hit := vectorHit{card: cardRecordFromResult(result)}
+		if additional, ok := result["_additional"].(map[string]any); ok {
+			hit.distance, _ = additional["distance"].(float64)
+			hit.vector = parseVector(additional["vector"])
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+func closestDeckCards(vector []float32, deck []deckVectorCard, n int) []DeckCardSimilarity {
+	similarities := make([]DeckCardSimilarity, 0, len(deck))
+	for _, deckCard := range deck {
+		similarities = append(similarities, DeckCardSimilarity{
+			Name:       deckCard.card.Name,
+			Similarity: cosineSimilarity(vector, deckCard.vector),
+		})
+	}
+	sort.SliceStable(similarities, func(i, j int) bool {
+		return similarities[i].Similarity > similarities[j].Similarity
+	})
+	return similarities[:min(n, len(similarities))]
+}
+
+// recommendForDeck searches around the whole-deck centroid and around each
+// cluster centroid, keeping the closest hit per oracle id that is not already
+// in the deck and fits its colour identity.
+func recommendForDeck(ctx context.Context, entries []DeckEntry, format string, limit int, clusters int) (MTGuruDeckRecommendResponse, error) {
+	response := MTGuruDeckRecommendResponse{
+		ColorIdentity:   deckColorIdentity(entries),
+		Format:          format,
+		Clusters:        []DeckCluster{},
+		Recommendations: []DeckRecommendation{},
+	}
+
+	inDeck := map[string]bool{}
+	quantities := map[string]int{}
+	var ids []string
+	cardsByID := map[string]CardRecord{}
+	for _, entry := range entries {
+		if entry.Card == nil {
+			continue
+		}
+		inDeck[entry.Card.OracleID] = true
+		if entry.Section != decklist.SectionMain && entry.Section != decklist.SectionCommander {
+			continue
+		}
+		// Basic lands are in every deck and would only drag the centroid around
+		if strings.Contains(entry.Card.TypeLine, "Basic") {
+			continue
+		}
+		if _, seen := cardsByID[entry.Card.ID]; !seen {
+			ids = append(ids, entry.Card.ID)
+			cardsByID[entry.Card.ID] = *entry.Card
+		}
+		quantities[entry.Card.ID] += entry.Quantity
+	}
+
+	vectors, err := fetchCardVectors(ctx, ids)
+	if err != nil {
+		return response, err
+	}
+
+	var deck []deckVectorCard
+	var deckVectors [][]float32
+	var weights []float64
+	for _, id := range ids {
+		if vector, ok := vectors[id]; ok {
+			deck = append(deck, deckVectorCard{card: cardsByID[id], vector: vector})
+			deckVectors = append(deckVectors, vector)
+			weights = append(weights, float64(quantities[id]))
+		}
+	}
+	if len(deck) == 0 {
+		return response, nil
+	}
+
+	if clusters <= 0 {
+		clusters = autoClusterCount(len(deck))
+	}
+	clusters = min(clusters, len(deck), maxClusters)
+	assignments := kMeans(deckVectors, weights, clusters, kMeansIterations)
+
+	// Cluster -1 is the whole deck
+	centroids := map[int][]float32{-1: weightedCentroid(deckVectors, weights)}
+	if clusters > 1 {
+		for c := 0; c < clusters; c++ {
+			var members [][]float32
+			var memberWeights []float64
+			cluster := DeckCluster{Index: c, Cards: []string{}}
+			for i, assignment := range assignments {
+				if assignment == c {
+					members = append(members, deckVectors[i])
+					memberWeights = append(memberWeights, weights[i])
+					cluster.Cards = append(cluster.Cards, deck[i].card.Name)
+				}
+			}
+			if len(members) > 0 {
+				centroids[c] = weightedCentroid(members, memberWeights)
+				response.Clusters = append(response.Clusters, cluster)
+			}
+		}
+	}
+
+	where := recommendationWhere(format)
+	// Weaviate filters cannot say "no colour outside the identity", so deck
+	// and off-colour cards are dropped here and each centroid pages on until
+	// it has found limit cards that fit
+	perCentroid := min(limit*4, 200)
+	best := map[string]DeckRecommendation{}
+	bestVectors := map[string][]float32{}
+	for c := -1; c < clusters; c++ {
+		centroid, ok := centroids[c]
+		if !ok {
+			continue
+		}
+		found := 0
+		for offset := 0; found < limit && offset < maxRecommendationScan; offset += perCentroid {
+			hits, err := searchNearVector(ctx, centroid, where, perCentroid, offset)
+			if err != nil {
+				return response, err
+			}
+			for _, hit := range hits {
+				if inDeck[hit.card.OracleID] || !withinColorIdentity(hit.card, response.ColorIdentity) {
+					continue
+				}
+				found++
+				if current, ok := best[hit.card.OracleID]; ok && current.Distance <= hit.distance {
+					continue
+				}
+				best[hit.card.OracleID] = DeckRecommendation{Card: hit.card, Distance: hit.distance, Cluster: c}
+				bestVectors[hit.card.OracleID] = hit.vector
+			}
+			if len(hits) < perCentroid {
+				break
+			}
+		}
+	}
+
+	for oracleID, recommendation := range best {
+		recommendation.ClosestDeckCards = closestDeckCards(bestVectors[oracleID], deck, closestDeckCardsPerResult)
+		response.Recommendations = append(response.Recommendations, recommendation)
+	}
+	sort.Slice(response.Recommendations, func(i, j int) bool {
+		return response.Recommendations[i].Distance < response.Recommendations[j].Distance
+	})
+	response.Recommendations = response.Recommendations[:min(limit, len(response.Recommendations))]
+
+	return response, nil
+}
+
+func deckRecommendHandler(w http.ResponseWriter, r *http.Request) {
+
+	var requestBody MTGuruDeckRecommendRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	deck, _, err := decklist.Parse(decklist.Detect([]byte(requestBody.Decklist)), strings.NewReader(requestBody.Decklist))
+	if err != nil {
+		writeValidationErrors(w, r, []fieldError{{Field: "decklist", Message: err.Error()}})
+		return
+	}
+
+	limit := requestBody.Limit
+	if limit <= 0 {
+		limit = defaultRecommendationLimit
+	}
+	slog.InfoContext(r.Context(), "Received deck recommendation request:", "entries", len(deck.Entries), "format", requestBody.Format, "limit", limit)
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	entries, unresolved, err := resolveDeck(ctx, deck)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	response, err := recommendForDeck(ctx, entries, requestBody.Format, limit, requestBody.Clusters)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+	response.Unresolved = unresolved
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mtguru/packages/config"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	rerankNone         = "none"
+	rerankHeuristic    = "heuristic"
+	rerankCrossEncoder = "cross_encoder"
+
+	defaultRerankCandidates = 100
+	maxRerankCandidates     = 200
+	defaultRerankTimeout    = 5 * time.Second
+	defaultRerankBatchSize  = 32
+)
+
+// rerankCandidate is the part of a hit a reranker scores.
+type rerankCandidate struct {
+	Name       string
+	TypeLine   string
+	OracleText string
+	// Retrieval is the first-stage relevance scaled to 0..1, higher is better
+	Retrieval float64
+}
+
+// Reranker re-scores the candidates retrieved from Weaviate, returning one
+// score per candidate where higher is more relevant.
+type Reranker interface {
+	Name() string
+	Rerank(ctx context.Context, query string, candidates []rerankCandidate) ([]float64, error)
+}
+
+// RerankInfo reports how a search's hits were reordered.
+type RerankInfo struct {
+	Reranker   string `json:"reranker"`
+	Candidates int    `json:"candidates" doc:"Hits retrieved from Weaviate before reranking"`
+	// Fallback is set when the reranker failed and retrieval order was kept
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// RerankScore is attached to each reranked hit as "rerank".
+type RerankScore struct {
+	Score         float64 `json:"score"`
+	RetrievalRank int     `json:"retrieval_rank" doc:"1-based position before reranking"`
+}
+
+// heuristicReranker blends retrieval relevance with how well the query's
+// words line up with the card's oracle text, type line and name.
+type heuristicReranker struct{}
+
+func (heuristicReranker) Name() string {
+	return rerankHeuristic
+}
+
+func stemSet(text string) map[string]bool {
+	stems := map[string]bool{}
+	for _, word := range queryTerms(text) {
+		stems[stem(word)] = true
+	}
+	return stems
+}
+
+func (heuristicReranker) Rerank(ctx context.Context, query string, candidates []rerankCandidate) ([]float64, error) {
+	terms := queryTerms(query)
+	normalizedQuery := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+
+	scores := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		oracle := stemSet(candidate.OracleText)
+		types := stemSet(candidate.TypeLine)
+		name := stemSet(candidate.Name)
+
+		var oracleHits, typeHits, nameHits int
+		for _, term := range terms {
+			s := stem(term)
+			if oracle[s] {
+				oracleHits++
+			}
+			if types[s] {
+				typeHits++
+			}
+			if name[s] {
+				nameHits++
+			}
+		}
+
+		score := 0.6 * candidate.Retrieval
+		if len(terms) > 0 {
+			n := float64(len(terms))
+			score += 0.25*float64(oracleHits)/n + 0.1*math.Min(float64(typeHits), 1) + 0.15*float64(nameHits)/n
+		}
+		// Someone typing a card's exact name wants that card first
+		if strings.ToLower(candidate.Name) == normalizedQuery || strings.ToLower(frontFaceName(candidate.Name)) == normalizedQuery {
+			score += 1
+		}
+		scores[i] = score
+	}
+	return scores, nil
+}
+
+// crossEncoderReranker calls a cross-encoder service using the
+// text-embeddings-inference /rerank shape: {"query", "texts"} in,
+// [{"index", "score"}] out. Any local stand-in speaking that shape works.
+// Candidates are sent batchSize at a time, since the service caps how many
+// texts one request may hold.
+type crossEncoderReranker struct {
+	url       string
+	client    *http.Client
+	batchSize int
+}
+
+type crossEncoderRequest struct {
+	Query string   `json:"query"`
+	Texts []string `json:"texts"`
+}
+
+type crossEncoderScore struct {
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+}
+
+func (c *crossEncoderReranker) Name() string {
+	return rerankCrossEncoder
+}
+
+func (c *crossEncoderReranker) Rerank(ctx context.Context, query string, candidates []rerankCandidate) ([]float64, error) {
+	texts := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		texts[i] = strings.Join([]string{candidate.Name, candidate.TypeLine, candidate.OracleText}, "\n")
+	}
+
+	batchSize := c.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultRerankBatchSize
+	}
+	scores := make([]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch, err := c.score(ctx, query, texts[start:min(start+batchSize, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, batch...)
+	}
+	return scores, nil
+}
+
+// score sends one batch. A response that leaves a text out or scores one
+// with NaN or an infinity is an error, so the search falls back to retrieval
+// order instead of ranking unscored hits.
+func (c *crossEncoderReranker) score(ctx context.Context, query string, texts []string) ([]float64, error) {
+	body, err := json.Marshal(crossEncoderRequest{Query: query, Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("cross-encoder returned status %d: %s", res.StatusCode, responseBody)
+	}
+
+	var results []crossEncoderScore
+	if err := json.Unmarshal(responseBody, &results); err != nil {
+		return nil, fmt.Errorf("invalid cross-encoder response: %w", err)
+	}
+	scores := make([]float64, len(texts))
+	scored := make([]bool, len(texts))
+	for _, result := range results {
+		if result.Index < 0 || result.Index >= len(scores) {
+			return nil, fmt.Errorf("cross-encoder returned index %d for %d texts", result.Index, len(texts))
+		}
+		if math.IsNaN(result.Score) || math.IsInf(result.Score, 0) {
+			return nil, fmt.Errorf("cross-encoder returned score %v for text %d", result.Score, result.Index)
+		}
+		scores[result.Index], scored[result.Index] = result.Score, true
+	}
+	for i, ok := range scored {
+		if !ok {
+			return nil, fmt.Errorf("cross-encoder returned no score for text %d of %d", i, len(texts))
+		}
+	}
+	return scores, nil
+}
+
+var rerankers = map[string]Reranker{}
+var defaultRerankerName = rerankNone
+
+// createRerankers registers the heuristic reranker, the cross-encoder when a
+// URL is configured, and picks the default used when a search names none.
+func createRerankers(conf config.RerankConfig) {
+	rerankers[rerankHeuristic] = heuristicReranker{}
+	if conf.URL != "" {
+		timeout := defaultRerankTimeout
+		if conf.TIMEOUT_MS > 0 {
+			timeout = time.Duration(conf.TIMEOUT_MS) * time.Millisecond
+		}
+		rerankers[rerankCrossEncoder] = &crossEncoderReranker{url: conf.URL, client: &http.Client{Timeout: timeout}, batchSize: conf.BATCH_SIZE}
+	}
+
+	switch {
+	case conf.DEFAULT == "" || conf.DEFAULT == rerankNone:
+		defaultRerankerName = rerankNone
+	case rerankers[conf.DEFAULT] != nil:
+		defaultRerankerName = conf.DEFAULT
+	default:
+		slog.Warn("Unknown or unconfigured default reranker, not reranking by default", "reranker", conf.DEFAULT)
+		defaultRerankerName = rerankNone
+	}
+}
+
+// rerankCandidatePool is how many hits to retrieve for a search that will be
+// reranked down to limit.
+func rerankCandidatePool(requested int, limit int) int {
+	pool := requested
+	if pool <= 0 {
+		pool = activeConfig.RERANK.CANDIDATES
+	}
+	if pool <= 0 {
+		pool = defaultRerankCandidates
+	}
+	return min(max(pool, limit), maxRerankCandidates)
+}
+
+// retrievalScores scales first-stage relevance to 0..1: one minus the cosine
+// distance for semantic search, the score relative to the best hit otherwise.
+func retrievalScores(cards []map[string]any) []float64 {
+	scores := make([]float64, len(cards))
+	best := 0.0
+	for i, card := range cards {
+		additional, _ := card["_additional"].(map[string]any)
+		if distance, ok := additional["distance"].(float64); ok {
+			scores[i] = math.Max(0, 1-distance)
+			continue
+		}
+		if score := parseScore(additional["score"]); score != nil {
+			scores[i] = *score
+			best = math.Max(best, *score)
+		}
+	}
+	if best > 0 {
+		for i, card := range cards {
+			additional, _ := card["_additional"].(map[string]any)
+			if _, ok := additional["distance"]; !ok {
+				scores[i] /= best
+			}
+		}
+	}
+	return scores
+}
+
+// rerankCards reorders cards by the reranker's scores and cuts them to limit.
+// If the reranker fails the retrieval order is kept and info.Fallback set.
+func rerankCards(ctx context.Context, reranker Reranker, query string, cards []map[string]any, limit int) ([]map[string]any, RerankInfo) {
+	info := RerankInfo{Reranker: reranker.Name(), Candidates: len(cards)}
+
+	candidates := make([]rerankCandidate, len(cards))
+	retrieval := retrievalScores(cards)
+	for i, card := range cards {
+		record := cardRecordFromResult(card)
+		candidates[i] = rerankCandidate{
+			Name:       record.Name,
+			TypeLine:   record.TypeLine,
+			OracleText: record.OracleText,
+			Retrieval:  retrieval[i],
+		}
+	}
+
+	scores, err := reranker.Rerank(ctx, query, candidates)
+	if err != nil || len(scores) != len(cards) {
+		slog.WarnContext(ctx, "Reranking failed, keeping retrieval order", "reranker", reranker.Name(), "error", err)
+		info.Fallback = true
+		return cards[:min(limit, len(cards))], info
+	}
+
+	order := make([]int, len(cards))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	reranked := make([]map[string]any, 0, min(limit, len(cards)))
+	for _, i := range order[:min(limit, len(order))] {
+		cards[i]["rerank"] = RerankScore{Score: scores[i], RetrievalRank: i + 1}
+		reranked = append(reranked, cards[i])
+	}
+	return reranked, info
+}
+
+// selectReranker resolves a request's rerank option, nil meaning no
+// reranking.
+func selectReranker(name string) (Reranker, error) {
+	if name == "" {
+		name = defaultRerankerName
+	}
+	if name == rerankNone {
+		return nil, nil
+	}
+	reranker, ok := rerankers[name]
+	if !ok {
+		return nil, newHTTPError(http.StatusBadRequest, errCodeInvalidRequest, "rerank "+name+" is not configured on this server", nil)
+	}
+	return reranker, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// crossEncoderStub scores each text by its position in the request and
+// leaves out the indexes in skip.
+func crossEncoderStub(t *testing.T, batchSizes *[]int, skip map[int]bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request crossEncoderRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*batchSizes = append(*batchSizes, len(request.Texts))
+		results := []crossEncoderScore{}
+		for i := range request.Texts {
+			if !skip[i] {
+				results = append(results, crossEncoderScore{Index: i, Score: float64(i)})
+			}
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+}
+
+func testCandidates(n int) ([]rerankCandidate, []map[string]any) {
+	candidates := make([]rerankCandidate, n)
+	cards := make([]map[string]any, n)
+	for i := range n {
+		name := fmt.Sprintf("Card %d", i)
+		candidates[i] = rerankCandidate{Name: name}
+		cards[i] = map[string]any{"name": name, "_additional": map[string]any{"distance": 0.1}}
+	}
+	return candidates, cards
+}
+
+func TestCrossEncoderBatches(t *testing.T) {
+	var batchSizes []int
+	server := crossEncoderStub(t, &batchSizes, nil)
+	defer server.Close()
+
+	reranker := &crossEncoderReranker{url: server.URL, client: server.Client(), batchSize: 2}
+	candidates, _ := testCandidates(5)
+	scores, err := reranker.Rerank(context.Background(), "query", candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(batchSizes) != "[2 2 1]" {
+		t.Errorf("batch sizes = %v, want [2 2 1]", batchSizes)
+	}
+	// Each batch scores its texts 0, 1, ...
+	if fmt.Sprint(scores) != "[0 1 0 1 0]" {
+		t.Errorf("scores = %v", scores)
+	}
+}
+
+func TestCrossEncoderPartialResponseFallsBack(t *testing.T) {
+	var batchSizes []int
+	server := crossEncoderStub(t, &batchSizes, map[int]bool{1: true})
+	defer server.Close()
+
+	reranker := &crossEncoderReranker{url: server.URL, client: server.Client()}
+	candidates, cards := testCandidates(3)
+	if _, err := reranker.Rerank(context.Background(), "query", candidates); err == nil {
+		t.Error("Rerank accepted a response without a score for every text")
+	}
+
+	reranked, info := rerankCards(context.Background(), reranker, "query", cards, 2)
+	if !info.Fallback {
+		t.Error("partial response did not fall back to retrieval order")
+	}
+	if len(reranked) != 2 || reranked[0]["name"] != "Card 0" || reranked[1]["name"] != "Card 1" {
+		t.Errorf("reranked = %v, want the first two in retrieval order", reranked)
+	}
+	if _, err := json.Marshal(reranked); err != nil {
+		t.Errorf("fallback hits do not marshal: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"mtguru/packages/analytics"
+	"net/http"
+)
+
+// apiRoute describes one endpoint. initHandler registers it on the mux and
+// the OpenAPI document is generated from the same list.
+type apiRoute struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Handler     http.HandlerFunc
+	// Request and Response are zero values of the JSON body types, nil if none
+	Request     any
+	Response    any
+	ContentType string
+	Params      []openAPIParameter
+	// Status is the success status, 0 for 200
+	Status int
+}
+
+var savedSearchIDParam = openAPIParameter{Name: "id", In: "path", Required: true, Schema: &openAPISchema{Type: "string"}}
+
+// cardQueryParams select the cards a random pick is drawn from.
+var cardQueryParams = []openAPIParameter{
+	{Name: "q", In: "query", Description: "Scryfall-style query: bare words match the name; t:, o:, c:, r:, s:, st:, a: (artist) and cmc (with :, =, <, <=, >, >=) filter", Schema: &openAPISchema{Type: "string"}},
+	{Name: "colors", In: "query", Schema: &openAPISchema{Type: "string", Enum: []any{"white", "blue", "black", "red", "green"}}},
+	{Name: "rarity", In: "query", Schema: &openAPISchema{Type: "string", Enum: []any{"common", "uncommon", "rare", "mythic", "special", "bonus"}}},
+	{Name: "set_type", In: "query", Schema: &openAPISchema{Type: "string"}},
+}
+
+func apiRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/search",
+			OperationID: "searchCards",
+			Summary:     "Semantic search over cards",
+			Handler:     searchHandler,
+			Request:     MTGuruSearchRequest{},
+			Response:    MTGuruSearchResponse{},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/search/stream",
+			OperationID: "searchCardsStream",
+			Summary:     "Semantic search streamed as NDJSON (default) or SSE (?format=sse or Accept: text/event-stream)",
+			Handler:     searchStreamHandler,
+			Request:     MTGuruSearchRequest{},
+			Response:    StreamEvent{},
+			ContentType: "application/x-ndjson",
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/search/batch",
+			OperationID: "searchCardsBatch",
+			Summary:     "Run several searches at once; each query succeeds or fails independently",
+			Handler:     batchSearchHandler,
+			Request:     MTGuruBatchSearchRequest{},
+			Response:    MTGuruBatchSearchResponse{},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/decks/analyze",
+			OperationID: "analyzeDeck",
+			Summary:     "Resolve a plain text decklist and report its curve, colours and types (also accepts text/plain)",
+			Handler:     deckAnalyzeHandler,
+			Request:     MTGuruDeckAnalyzeRequest{},
+			Response:    MTGuruDeckAnalysis{},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/decks/convert",
+			OperationID: "convertDeck",
+			Summary:     "Convert a decklist between plain text, MTG Arena, MTGO .dek and Moxfield/Archidekt CSV",
+			Handler:     deckConvertHandler,
+			Request:     MTGuruDeckConvertRequest{},
+			Response:    MTGuruDeckConvertResponse{},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/decks/recommend",
+			OperationID: "recommendForDeck",
+			Summary:     "Recommend cards near the deck's vector centroids, within its colour identity and format",
+			Handler:     deckRecommendHandler,
+			Request:     MTGuruDeckRecommendRequest{},
+			Response:    MTGuruDeckRecommendResponse{},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/cards/random",
+			OperationID: "getRandomCard",
+			Summary:     "A random card, each oracle card equally likely, optionally matching a Scryfall-style query",
+			Handler:     randomCardHandler,
+			Response:    MTGuruRandomCardResponse{},
+			Params:      cardQueryParams,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/cards/daily",
+			OperationID: "getCardOfTheDay",
+			Summary:     "The card of the day: a random card picked deterministically from the date and query",
+			Handler:     dailyCardHandler,
+			Response:    MTGuruRandomCardResponse{},
+			Params: append([]openAPIParameter{
+				{Name: "date", In: "query", Description: "Day to pick for as YYYY-MM-DD, default today (UTC)", Schema: &openAPISchema{Type: "string", Format: "date"}},
+			}, cardQueryParams...),
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/cards/{id}",
+			OperationID: "getCard",
+			Summary:     "A card by its object id, with its Scryfall rulings",
+			Handler:     cardHandler,
+			Response:    MTGuruCardResponse{},
+			Params: []openAPIParameter{
+				{Name: "id", In: "path", Required: true, Schema: &openAPISchema{Type: "string", Format: "uuid"}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/sets",
+			OperationID: "listSets",
+			Summary:     "Every ingested Scryfall set and the list of set types",
+			Handler:     setsHandler,
+			Response:    MTGuruSetList{},
+			Params: []openAPIParameter{
+				{Name: "set_type", In: "query", Description: "Only list sets of this type", Schema: &openAPISchema{Type: "string"}},
+				{Name: "sort", In: "query", Description: "released_at (newest first, the default), name or code", Schema: &openAPISchema{Type: "string", Enum: []any{"released_at", "name", "code"}}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/sets/{code}/cards",
+			OperationID: "listSetCards",
+			Summary:     "A set and every printing in it",
+			Handler:     setCardsHandler,
+			Response:    MTGuruSetCards{},
+			Params: []openAPIParameter{
+				{Name: "code", In: "path", Required: true, Description: "Set code, e.g. neo", Schema: &openAPISchema{Type: "string"}},
+				{Name: "sort", In: "query", Description: "collector_number (the default), rarity or name; ties go by collector number", Schema: &openAPISchema{Type: "string", Enum: []any{"collector_number", "rarity", "name"}}},
+				{Name: "order", In: "query", Schema: &openAPISchema{Type: "string", Enum: []any{"asc", "desc"}}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/artists",
+			OperationID: "searchArtists",
+			Summary:     "Artists whose name contains every word of q, with how many printings each illustrated",
+			Handler:     artistsHandler,
+			Response:    MTGuruArtistList{},
+			Params: []openAPIParameter{
+				{Name: "q", In: "query", Required: true, Description: "Words of the artist's name", Schema: &openAPISchema{Type: "string"}},
+				{Name: "limit", In: "query", Description: "Number of artists, default 20, at most 100", Schema: &openAPISchema{Type: "integer"}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/artists/{id}/cards",
+			OperationID: "listArtistCards",
+			Summary:     "Every printing an artist illustrated, with counts by set and year",
+			Handler:     artistCardsHandler,
+			Response:    MTGuruArtistCards{},
+			Params: []openAPIParameter{
+				{Name: "id", In: "path", Required: true, Description: "Scryfall artist id", Schema: &openAPISchema{Type: "string", Format: "uuid"}},
+			},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/rulings/search",
+			OperationID: "searchRulings",
+			Summary:     "Semantic search over Scryfall rulings, for rules questions",
+			Handler:     rulingsSearchHandler,
+			Request:     MTGuruRulingsSearchRequest{},
+			Response:    MTGuruRulingsSearchResponse{},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/ask",
+			OperationID: "askQuestion",
+			Summary:     "Answer a question from retrieved cards, citing the card ids used",
+			Handler:     askHandler,
+			Request:     MTGuruAskRequest{},
+			Response:    MTGuruAskResponse{},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/saved-searches",
+			OperationID: "listSavedSearches",
+			Summary:     "The user's saved searches",
+			Handler:     listSavedSearchesHandler,
+			Response:    MTGuruSavedSearchList{},
+			Params:      []openAPIParameter{userIDParam},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/saved-searches",
+			OperationID: "createSavedSearch",
+			Summary:     "Save a search body under a name",
+			Handler:     createSavedSearchHandler,
+			Request:     MTGuruSavedSearchRequest{},
+			Response:    SavedSearch{},
+			Params:      []openAPIParameter{userIDParam},
+			Status:      http.StatusCreated,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/saved-searches/{id}",
+			OperationID: "getSavedSearch",
+			Summary:     "A saved search",
+			Handler:     getSavedSearchHandler,
+			Response:    SavedSearch{},
+			Params:      []openAPIParameter{userIDParam, savedSearchIDParam},
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/api/saved-searches/{id}",
+			OperationID: "updateSavedSearch",
+			Summary:     "Rename a saved search or replace its search body",
+			Handler:     updateSavedSearchHandler,
+			Request:     MTGuruSavedSearchRequest{},
+			Response:    SavedSearch{},
+			Params:      []openAPIParameter{userIDParam, savedSearchIDParam},
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/api/saved-searches/{id}",
+			OperationID: "deleteSavedSearch",
+			Summary:     "Delete a saved search, returning it",
+			Handler:     deleteSavedSearchHandler,
+			Response:    SavedSearch{},
+			Params:      []openAPIParameter{userIDParam, savedSearchIDParam},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/saved-searches/{id}/run",
+			OperationID: "runSavedSearch",
+			Summary:     "Re-run a saved search and diff its results against the previous run",
+			Handler:     runSavedSearchHandler,
+			Response:    MTGuruSavedSearchRun{},
+			Params:      []openAPIParameter{userIDParam, savedSearchIDParam},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/favorites",
+			OperationID: "listFavorites",
+			Summary:     "The user's favorite cards",
+			Handler:     listFavoritesHandler,
+			Response:    MTGuruFavoriteList{},
+			Params:      []openAPIParameter{userIDParam},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/favorites",
+			OperationID: "addFavorite",
+			Summary:     "Add a card to the user's favorites, or update its note",
+			Handler:     addFavoriteHandler,
+			Request:     MTGuruFavoriteRequest{},
+			Response:    Favorite{},
+			Params:      []openAPIParameter{userIDParam},
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/api/favorites/{card_id}",
+			OperationID: "deleteFavorite",
+			Summary:     "Remove a card from the user's favorites, returning the favorite",
+			Handler:     deleteFavoriteHandler,
+			Response:    Favorite{},
+			Params: []openAPIParameter{
+				userIDParam,
+				{Name: "card_id", In: "path", Required: true, Schema: &openAPISchema{Type: "string", Format: "uuid"}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/admin/analytics",
+			OperationID: "getSearchAnalytics",
+			Summary:     "Top, zero-result and poorly matching queries from the search analytics log (admin bearer token)",
+			Handler:     analyticsReportHandler,
+			Response:    analytics.Report{},
+			Params: []openAPIParameter{
+				{Name: "since", In: "query", Description: "How far back to report, as a Go duration such as 24h; default 168h", Schema: &openAPISchema{Type: "string"}},
+				{Name: "limit", In: "query", Description: "Entries per list, default 20", Schema: &openAPISchema{Type: "integer"}},
+				{Name: "poor_distance", In: "query", Description: "Best-hit distance above which a query is a poor match", Schema: &openAPISchema{Type: "number"}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/images/{scryfall_id}/{size}",
+			OperationID: "getCardImage",
+			Summary:     "A card image from the local cache, fetched from the upstream on a miss",
+			Handler:     imageHandler,
+			ContentType: "image/*",
+			Params: []openAPIParameter{
+				{Name: "scryfall_id", In: "path", Required: true, Schema: &openAPISchema{Type: "string", Format: "uuid"}},
+				{Name: "size", In: "path", Required: true, Schema: &openAPISchema{Type: "string", Enum: []any{"small", "normal", "large", "png", "art_crop", "border_crop"}}},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/openapi.json",
+			OperationID: "getOpenAPI",
+			Summary:     "This OpenAPI document",
+			Handler:     openAPIHandler,
+			ContentType: "application/json",
+		},
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// rulingsClass is written by the ingestion service, one object per Scryfall
+// ruling keyed by oracle_id and vectorized on the comment.
+const rulingsClass = "MtguruRuling"
+
+const defaultRulingsLimit = 10
+
+type CardRuling struct {
+	OracleID    string `json:"oracle_id"`
+	Source      string `json:"source" doc:"wotc or scryfall"`
+	PublishedAt string `json:"published_at"`
+	Comment     string `json:"comment"`
+}
+
+type MTGuruRulingsSearchRequest struct {
+	Query string `json:"query" openapi:"required,minLength=1,maxLength=500"`
+	Limit int    `json:"limit" openapi:"minimum=0,maximum=50" doc:"Number of rulings, 0 uses the default"`
+}
+
+type RulingHit struct {
+	CardRuling
+	Distance float64      `json:"distance"`
+	Cards    []CardRecord `json:"cards" doc:"Cards sharing the ruling's oracle id"`
+}
+
+type MTGuruRulingsSearchResponse struct {
+	Results []RulingHit `json:"results"`
+}
+
+var rulingFields = []graphql.Field{
+	{Name: "oracle_id"},
+	{Name: "source"},
+	{Name: "published_at"},
+	{Name: "comment"},
+}
+
+func rulingFromResult(result map[string]any) CardRuling {
+	var ruling CardRuling
+	ruling.OracleID, _ = result["oracle_id"].(string)
+	ruling.Source, _ = result["source"].(string)
+	ruling.PublishedAt, _ = result["published_at"].(string)
+	ruling.Comment, _ = result["comment"].(string)
+	return ruling
+}
+
+// fetchRulings returns the rulings for each oracle id, oldest first as
+// Scryfall lists them. Oracle ids without rulings are absent.
+func fetchRulings(ctx context.Context, oracleIDs []string) (map[string][]CardRuling, error) {
+	rulings := map[string][]CardRuling{}
+
+	for start := 0; start < len(oracleIDs); start += namesPerLookup {
+		end := min(start+namesPerLookup, len(oracleIDs))
+
+		operands := make([]*filters.WhereBuilder, 0, end-start)
+		for _, id := range oracleIDs[start:end] {
+			operands = append(operands, filters.Where().
+				WithPath([]string{"oracle_id"}).
+				WithOperator(filters.Equal).
+				WithValueString(id))
+		}
+
+		response, err := client.GraphQL().Get().
+			WithClassName(rulingsClass).
+			WithFields(rulingFields...).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands(operands)).
+			// Cards rarely have more than a few dozen rulings
+			WithLimit((end - start) * 50).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+
+		for _, result := range resultObjects(response, rulingsClass) {
+			ruling := rulingFromResult(result)
+			rulings[ruling.OracleID] = append(rulings[ruling.OracleID], ruling)
+		}
+	}
+
+	for _, cardRulings := range rulings {
+		sort.SliceStable(cardRulings, func(i, j int) bool {
+			return cardRulings[i].PublishedAt < cardRulings[j].PublishedAt
+		})
+	}
+	return rulings, nil
+}
+
+// lookupCardsByOracleID returns every ingested card for each oracle id.
+func lookupCardsByOracleID(ctx context.Context, oracleIDs []string) (map[string][]CardRecord, error) {
+	cards := map[string][]CardRecord{}
+
+	for start := 0; start < len(oracleIDs); start += namesPerLookup {
+		end := min(start+namesPerLookup, len(oracleIDs))
+
+		operands := make([]*filters.WhereBuilder, 0, end-start)
+		for _, id := range oracleIDs[start:end] {
+			operands = append(operands, filters.Where().
+				WithPath([]string{"oracle_id"}).
+				WithOperator(filters.Equal).
+				WithValueString(id))
+		}
+
+		response, err := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(cardFields("id")...).
+			WithWhere(filters.Where().WithOperator(filters.Or).WithOperands(operands)).
+			WithLimit((end - start) * 4).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphQLError(response); err != nil {
+			return nil, err
+		}
+
+		for _, result := range resultCards(response) {
+			card := cardRecordFromResult(result)
+			cards[card.OracleID] = append(cards[card.OracleID], card)
+		}
+	}
+
+	return cards, nil
+}
+
+func searchRulings(ctx context.Context, query string, limit int) ([]RulingHit, error) {
+	response, err := client.GraphQL().Get().
+		WithClassName(rulingsClass).
+		WithFields(append(append([]graphql.Field{}, rulingFields...), graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "distance"}}})...).
+		WithNearText(client.GraphQL().NearTextArgBuilder().WithConcepts([]string{query})).
+		WithLimit(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+
+	hits := []RulingHit{}
+	var oracleIDs []string
+	for _, result := range resultObjects(response, rulingsClass) {
+		hit := RulingHit{CardRuling: rulingFromResult(result), Cards: []CardRecord{}}
+		if additional, ok := result["_additional"].(map[string]any); ok {
+			hit.Distance, _ = additional["distance"].(float64)
+		}
+		hits = append(hits, hit)
+		oracleIDs = append(oracleIDs, hit.OracleID)
+	}
+
+	cards, err := lookupCardsByOracleID(ctx, oracleIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		if matched, ok := cards[hits[i].OracleID]; ok {
+			hits[i].Cards = matched
+		}
+	}
+	return hits, nil
+}
+
+func rulingsSearchHandler(w http.ResponseWriter, r *http.Request) {
+
+	var requestBody MTGuruRulingsSearchRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	limit := requestBody.Limit
+	if limit <= 0 {
+		limit = defaultRulingsLimit
+	}
+	slog.InfoContext(r.Context(), "Received rulings search request:", "query", requestBody.Query, "limit", limit)
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	hits, err := searchRulings(ctx, requestBody.Query, limit)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MTGuruRulingsSearchResponse{Results: hits})
+}
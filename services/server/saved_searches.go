@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// There are no accounts yet, so saved searches and favorites are scoped by
+// an opaque X-User-ID header chosen by the client.
+const userIDHeader = "X-User-ID"
+
+var userIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+var userIDParam = openAPIParameter{
+	Name:        userIDHeader,
+	In:          "header",
+	Description: "Opaque id the user's saved searches and favorites are stored under",
+	Required:    true,
+	Schema:      &openAPISchema{Type: "string"},
+}
+
+type SavedSearch struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Search    MTGuruSearchRequest `json:"search"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	LastRunAt *time.Time          `json:"last_run_at,omitempty"`
+	// LastResultIDs are the object ids returned by the last run, for diffing
+	LastResultIDs []string `json:"last_result_ids,omitempty"`
+}
+
+type MTGuruSavedSearchRequest struct {
+	Name   string              `json:"name" openapi:"required,minLength=1,maxLength=100"`
+	Search MTGuruSearchRequest `json:"search" openapi:"required"`
+}
+
+type MTGuruSavedSearchList struct {
+	SavedSearches []SavedSearch `json:"saved_searches"`
+}
+
+type MTGuruSavedSearchRun struct {
+	SavedSearch   SavedSearch      `json:"saved_search"`
+	PreviousRunAt *time.Time       `json:"previous_run_at,omitempty"`
+	Results       []map[string]any `json:"results"`
+	Added         []map[string]any `json:"added" doc:"Results that were not returned by the previous run, e.g. newly ingested cards; every result on the first run"`
+	Removed       []string         `json:"removed" doc:"Object ids returned by the previous run but not this one"`
+}
+
+// requireUserID reads the X-User-ID header, writing an error response and
+// returning false if it is missing or malformed.
+func requireUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get(userIDHeader)
+	if !userIDPattern.MatchString(userID) {
+		writeError(w, r, http.StatusUnauthorized, APIError{
+			Code:    errCodeMissingUser,
+			Message: userIDHeader + " header must be 1-64 letters, digits or _.@-",
+			Field:   userIDHeader,
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+// writeStoreError reports a store failure, mapping missing records to 404.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+	if errors.Is(err, errRecordNotFound) {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: notFound})
+		return
+	}
+	writeErrorFrom(w, r, err)
+}
+
+// diffResults compares a run's results with the object ids of the previous
+// run. With no previous run every result counts as added.
+func diffResults(results []map[string]any, previous []string) (ids []string, added []map[string]any, removed []string) {
+	ids = []string{}
+	added = []map[string]any{}
+	removed = []string{}
+	for _, result := range results {
+		additional, _ := result["_additional"].(map[string]any)
+		id, _ := additional["id"].(string)
+		ids = append(ids, id)
+		if !slices.Contains(previous, id) {
+			added = append(added, result)
+		}
+	}
+	for _, id := range previous {
+		if !slices.Contains(ids, id) {
+			removed = append(removed, id)
+		}
+	}
+	return ids, added, removed
+}
+
+func listSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	response := MTGuruSavedSearchList{SavedSearches: []SavedSearch{}}
+	err := listRecords(userID, searchesBucket, func(search SavedSearch) {
+		response.SavedSearches = append(response.SavedSearches, search)
+	})
+	if err != nil {
+		writeErrorFrom(w, r, err)
+		return
+	}
+	slices.SortStableFunc(response.SavedSearches, func(a, b SavedSearch) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func createSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestBody MTGuruSavedSearchRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	now := time.Now().UTC()
+	search := SavedSearch{
+		ID:        newRequestID(),
+		Name:      requestBody.Name,
+		Search:    requestBody.Search,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := putRecord(userID, searchesBucket, search.ID, search); err != nil {
+		writeErrorFrom(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Saved search created", "user_id", userID, "id", search.ID)
+
+	w.Header().Set("Location", "/api/saved-searches/"+search.ID)
+	writeJSON(w, http.StatusCreated, search)
+}
+
+func getSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var search SavedSearch
+	if err := getRecord(userID, searchesBucket, r.PathValue("id"), &search); err != nil {
+		writeStoreError(w, r, err, "No saved search with id "+r.PathValue("id"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, search)
+}
+
+func updateSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestBody MTGuruSavedSearchRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	var search SavedSearch
+	err := updateRecord(userID, searchesBucket, r.PathValue("id"), func(stored *SavedSearch) error {
+		stored.Name = requestBody.Name
+		stored.Search = requestBody.Search
+		stored.UpdatedAt = time.Now().UTC()
+		search = *stored
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, r, err, "No saved search with id "+r.PathValue("id"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, search)
+}
+
+func deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var search SavedSearch
+	if err := getRecord(userID, searchesBucket, r.PathValue("id"), &search); err != nil {
+		writeStoreError(w, r, err, "No saved search with id "+r.PathValue("id"))
+		return
+	}
+	if err := deleteRecord(userID, searchesBucket, search.ID); err != nil {
+		writeStoreError(w, r, err, "No saved search with id "+search.ID)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, search)
+}
+
+// runSavedSearchHandler re-runs a saved search, reports how its results
+// changed since the last run and remembers this run for the next diff.
+func runSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var search SavedSearch
+	if err := getRecord(userID, searchesBucket, r.PathValue("id"), &search); err != nil {
+		writeStoreError(w, r, err, "No saved search with id "+r.PathValue("id"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	response, err := executeSearch(ctx, search.Search, searchLimit(search.Search.Limit))
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	// The record is re-read when the run is recorded, so the diff is against
+	// whichever run finished last and edits made meanwhile are kept
+	results := response.cards()
+	run := MTGuruSavedSearchRun{Results: results}
+	err = updateRecord(userID, searchesBucket, search.ID, func(stored *SavedSearch) error {
+		ids, added, removed := diffResults(results, stored.LastResultIDs)
+		run.PreviousRunAt, run.Added, run.Removed = stored.LastRunAt, added, removed
+
+		now := time.Now().UTC()
+		stored.LastRunAt = &now
+		stored.LastResultIDs = ids
+		run.SavedSearch = *stored
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, r, err, "No saved search with id "+search.ID)
+		return
+	}
+	slog.InfoContext(r.Context(), "Saved search run", "user_id", userID, "id", search.ID, "results", len(results), "added", len(run.Added), "removed", len(run.Removed))
+
+	writeJSON(w, http.StatusOK, run)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// The Mtguru schema is re-read at most this often, so a re-ingest that adds
+// properties is picked up without a restart.
+const schemaRefreshInterval = time.Minute
+
+const schemaFetchTimeout = 2 * time.Second
+
+// collectionSchema caches the property names of the Mtguru class.
+var collectionSchema struct {
+	sync.Mutex
+	properties map[string]bool
+	checkedAt  time.Time
+}
+
+// mtguruProperties returns the property names of the Mtguru class, or nil
+// if the schema could not be read. The lock is never held across the fetch:
+// once the schema has been read, a stale copy is served while a background
+// refresh runs, and only the very first read waits for Weaviate.
+func mtguruProperties() map[string]bool {
+	collectionSchema.Lock()
+	properties := collectionSchema.properties
+	if time.Since(collectionSchema.checkedAt) < schemaRefreshInterval {
+		collectionSchema.Unlock()
+		return properties
+	}
+	// Marking it checked before fetching keeps concurrent callers from
+	// starting fetches of their own
+	collectionSchema.checkedAt = time.Now()
+	collectionSchema.Unlock()
+
+	if properties == nil {
+		return refreshSchema()
+	}
+	go refreshSchema()
+	return properties
+}
+
+// refreshSchema reads the Mtguru class and caches its property names. On
+// failure the cached properties, if any, are kept and returned.
+func refreshSchema() map[string]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), schemaFetchTimeout)
+	defer cancel()
+	class, err := client.Schema().ClassGetter().WithClassName("Mtguru").Do(ctx)
+	if err != nil {
+		slog.Warn("Could not read the Mtguru schema", "error", err.Error())
+		collectionSchema.Lock()
+		defer collectionSchema.Unlock()
+		return collectionSchema.properties
+	}
+
+	properties := make(map[string]bool, len(class.Properties))
+	for _, property := range class.Properties {
+		properties[property.Name] = true
+	}
+	collectionSchema.Lock()
+	collectionSchema.properties = properties
+	collectionSchema.Unlock()
+	return properties
+}
+
+// presentFields drops the fields the Mtguru class does not have. A collection
+// ingested before layout, card_faces, set, collector_number and the other
+// later properties were added then still answers queries, with those fields
+// left empty until it is re-ingested. _additional is always kept.
+func presentFields(fields []graphql.Field) []graphql.Field {
+	properties := mtguruProperties()
+	if properties == nil {
+		return fields
+	}
+
+	present := make([]graphql.Field, 0, len(fields))
+	for _, field := range fields {
+		if field.Name == "_additional" || properties[field.Name] {
+			present = append(present, field)
+		}
+	}
+	return present
+}
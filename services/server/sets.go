@@ -0,0 +1,233 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// setsClass is written by the ingestion service from Scryfall's set list.
+const setsClass = "MtguruSet"
+
+// Scryfall lists fewer than a thousand sets; this leaves room to grow.
+const maxSets = 5000
+
+// maxSetCards bounds the printings returned for one set.
+const maxSetCards = 2000
+
+var setCodePattern = regexp.MustCompile(`^[a-z0-9]{2,6}$`)
+
+type MTGuruSet struct {
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	SetType       string `json:"set_type"`
+	ReleasedAt    string `json:"released_at" doc:"YYYY-MM-DD"`
+	CardCount     int    `json:"card_count" doc:"Cards in the set according to Scryfall, counting every printing"`
+	ParentSetCode string `json:"parent_set_code,omitempty" doc:"The main set of promo, token and similar sets"`
+	Block         string `json:"block,omitempty"`
+	Digital       bool   `json:"digital"`
+	IconSvgURI    string `json:"icon_svg_uri,omitempty"`
+	ScryfallURI   string `json:"scryfall_uri,omitempty"`
+}
+
+type MTGuruSetList struct {
+	Sets     []MTGuruSet `json:"sets"`
+	SetTypes []string    `json:"set_types" doc:"Every set type, sorted, for filter dropdowns"`
+}
+
+type MTGuruSetCards struct {
+	Set MTGuruSet `json:"set"`
+	// Cards come from MtguruPrinting, so reprints and alternate printings
+	// are listed and each id is that printing's Scryfall id
+	Cards []CardRecord `json:"cards" doc:"Every printing in the set"`
+}
+
+var setFields = []graphql.Field{
+	{Name: "code"},
+	{Name: "name"},
+	{Name: "set_type"},
+	{Name: "released_at"},
+	{Name: "card_count"},
+	{Name: "parent_set_code"},
+	{Name: "block"},
+	{Name: "digital"},
+	{Name: "icon_svg_uri"},
+	{Name: "scryfall_uri"},
+}
+
+// rarityOrder ranks rarities from most to least common.
+var rarityOrder = map[string]int{
+	"common": 0, "uncommon": 1, "rare": 2, "mythic": 3, "special": 4, "bonus": 5,
+}
+
+// fetchSets returns the sets matching where, or every set when where is nil.
+func fetchSets(ctx context.Context, where *filters.WhereBuilder) ([]MTGuruSet, error) {
+	get := client.GraphQL().Get().
+		WithClassName(setsClass).
+		WithFields(setFields...).
+		WithLimit(maxSets)
+	if where != nil {
+		get = get.WithWhere(where)
+	}
+	response, err := get.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := graphQLError(response); err != nil {
+		return nil, err
+	}
+
+	results := resultObjects(response, setsClass)
+	sets := make([]MTGuruSet, 0, len(results))
+	for _, result := range results {
+		var set MTGuruSet
+		if b, err := json.Marshal(result); err == nil {
+			json.Unmarshal(b, &set)
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// compareCollectorNumbers orders "2" before "10" and "10" before "10a",
+// falling back to plain string order for numbers like "A-12".
+func compareCollectorNumbers(a string, b string) int {
+	numberA, suffixA := splitCollectorNumber(a)
+	numberB, suffixB := splitCollectorNumber(b)
+	if numberA < 0 || numberB < 0 {
+		return strings.Compare(a, b)
+	}
+	return cmp.Or(cmp.Compare(numberA, numberB), strings.Compare(suffixA, suffixB))
+}
+
+func splitCollectorNumber(number string) (int, string) {
+	digits := len(number) - len(strings.TrimLeft(number, "0123456789"))
+	n, err := strconv.Atoi(number[:digits])
+	if err != nil {
+		return -1, number
+	}
+	return n, number[digits:]
+}
+
+func setsHandler(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	sortBy := cmp.Or(query.Get("sort"), "released_at")
+	if sortBy != "released_at" && sortBy != "name" && sortBy != "code" {
+		writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "sort must be released_at, name or code", Field: "sort"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	sets, err := fetchSets(ctx, nil)
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	// set_types lists every type, even when the sets are narrowed to one
+	response := MTGuruSetList{Sets: []MTGuruSet{}, SetTypes: []string{}}
+	for _, set := range sets {
+		if !slices.Contains(response.SetTypes, set.SetType) {
+			response.SetTypes = append(response.SetTypes, set.SetType)
+		}
+		if setType := query.Get("set_type"); setType == "" || set.SetType == setType {
+			response.Sets = append(response.Sets, set)
+		}
+	}
+	slices.Sort(response.SetTypes)
+
+	slices.SortStableFunc(response.Sets, func(a, b MTGuruSet) int {
+		switch sortBy {
+		case "name":
+			return strings.Compare(a.Name, b.Name)
+		case "code":
+			return strings.Compare(a.Code, b.Code)
+		}
+		// Newest first
+		return cmp.Or(strings.Compare(b.ReleasedAt, a.ReleasedAt), strings.Compare(a.Code, b.Code))
+	})
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func setCardsHandler(w http.ResponseWriter, r *http.Request) {
+
+	code := strings.ToLower(r.PathValue("code"))
+	if !setCodePattern.MatchString(code) {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No set with code " + code})
+		return
+	}
+	sortBy := cmp.Or(r.URL.Query().Get("sort"), "collector_number")
+	if sortBy != "collector_number" && sortBy != "rarity" && sortBy != "name" {
+		writeError(w, r, http.StatusBadRequest, APIError{Code: errCodeInvalidRequest, Message: "sort must be collector_number, rarity or name", Field: "sort"})
+		return
+	}
+	descending := r.URL.Query().Get("order") == "desc"
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	sets, err := fetchSets(ctx, filters.Where().
+		WithPath([]string{"code"}).
+		WithOperator(filters.Equal).
+		WithValueString(code))
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+	if len(sets) == 0 {
+		writeError(w, r, http.StatusNotFound, APIError{Code: errCodeNotFound, Message: "No set with code " + code})
+		return
+	}
+
+	response, err := client.GraphQL().Get().
+		WithClassName(printingsClass).
+		WithFields(printingFields(nil, "id")...).
+		WithWhere(filters.Where().
+			WithPath([]string{"set"}).
+			WithOperator(filters.Equal).
+			WithValueString(code)).
+		WithLimit(maxSetCards).
+		Do(ctx)
+	if err == nil {
+		err = graphQLError(response)
+	}
+	if err != nil {
+		writeErrorFrom(w, r, classifySearchError(r.Context(), ctx, err))
+		return
+	}
+
+	cards := []CardRecord{}
+	for _, result := range resultObjects(response, printingsClass) {
+		cards = append(cards, cardRecordFromResult(result))
+	}
+	slices.SortStableFunc(cards, func(a, b CardRecord) int {
+		byNumber := compareCollectorNumbers(a.CollectorNumber, b.CollectorNumber)
+		var order int
+		switch sortBy {
+		case "rarity":
+			order = cmp.Or(cmp.Compare(rarityOrder[a.Rarity], rarityOrder[b.Rarity]), byNumber)
+		case "name":
+			order = cmp.Or(strings.Compare(a.Name, b.Name), byNumber)
+		default:
+			order = byNumber
+		}
+		if descending {
+			return -order
+		}
+		return order
+	})
+
+	writeJSON(w, http.StatusOK, MTGuruSetCards{Set: sets[0], Cards: cards})
+}
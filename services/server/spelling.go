@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"mtguru/packages/spelling"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+const vocabularyPageSize = 1000
+
+// Building the vocabulary is retried from vocabularyRetryMin, doubling up to
+// vocabularyRetryMax, while Weaviate is unreachable or has no cards yet.
+const (
+	vocabularyRetryMin = 5 * time.Second
+	vocabularyRetryMax = 5 * time.Minute
+)
+
+// vocabulary is nil until loadVocabulary has read the collection; searches
+// made before then are not corrected.
+var vocabulary atomic.Pointer[spelling.Vocabulary]
+
+// commonQueryWords are words people use to describe cards that rarely
+// appear on them, so they are not "corrected" into card words.
+var commonQueryWords = []string{
+	"cheap", "cheaper", "cheapest", "good", "best", "better", "strong", "powerful",
+	"efficient", "budget", "cards", "card", "deck", "decks", "commander", "combo",
+	"synergy", "effects", "effect", "things", "stuff", "lots", "lot", "like",
+	"similar", "ways", "something", "wins", "winning", "game", "games",
+}
+
+// loadVocabulary builds the spelling vocabulary, retrying with backoff until
+// it succeeds, so a server started before Weaviate is ready or before the
+// cards are ingested still gets correction once they are.
+func loadVocabulary(ctx context.Context) {
+	delay := vocabularyRetryMin
+	for {
+		start := time.Now()
+		v, cards, err := buildVocabulary(ctx)
+		if err == nil && cards > 0 {
+			vocabulary.Store(v)
+			slog.Info("Spelling vocabulary built", "cards", cards, "words", v.Len(), "duration_ms", time.Since(start).Milliseconds())
+			return
+		}
+		if err != nil {
+			slog.Warn("Could not build spelling vocabulary, queries are not corrected yet", "error", err.Error(), "retry_in", delay.String())
+		} else {
+			slog.Warn("No cards to build the spelling vocabulary from yet", "retry_in", delay.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, vocabularyRetryMax)
+	}
+}
+
+// buildVocabulary pages through every card and builds the spelling vocabulary
+// from names, type lines (types and subtypes), keywords, oracle and flavor
+// text. Oracle and flavor text supply the plain English people search with.
+func buildVocabulary(ctx context.Context) (*spelling.Vocabulary, int, error) {
+	v := spelling.NewVocabulary()
+	for _, word := range commonQueryWords {
+		v.Add(word)
+	}
+	for word := range queryStopwords {
+		v.Add(word)
+	}
+	if synonymDictionary != nil {
+		for _, term := range synonymDictionary.Terms() {
+			v.Add(term)
+		}
+	}
+
+	cursor := ""
+	cards := 0
+	for {
+		get := client.GraphQL().Get().
+			WithClassName("Mtguru").
+			WithFields(
+				graphql.Field{Name: "name"},
+				graphql.Field{Name: "type_line"},
+				graphql.Field{Name: "keywords"},
+				graphql.Field{Name: "oracle_text"},
+				graphql.Field{Name: "flavor_text"},
+				graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "id"}}},
+			).
+			WithLimit(vocabularyPageSize)
+		if cursor != "" {
+			get = get.WithAfter(cursor)
+		}
+
+		response, err := get.Do(ctx)
+		if err == nil {
+			err = graphQLError(response)
+		}
+		if err != nil {
+			return nil, cards, err
+		}
+
+		results := resultCards(response)
+		for _, result := range results {
+			for _, field := range []string{"name", "type_line", "oracle_text", "flavor_text"} {
+				if text, ok := result[field].(string); ok {
+					v.Add(text)
+				}
+			}
+			if keywords, ok := result["keywords"].([]any); ok {
+				for _, keyword := range keywords {
+					if text, ok := keyword.(string); ok {
+						v.Add(text)
+					}
+				}
+			}
+			if additional, ok := result["_additional"].(map[string]any); ok {
+				cursor, _ = additional["id"].(string)
+			}
+		}
+		cards += len(results)
+		if len(results) < vocabularyPageSize {
+			break
+		}
+	}
+
+	return v, cards, nil
+}
+
+// correctSpelling returns the corrected query, or the query unchanged when
+// the vocabulary is not loaded yet.
+func correctSpelling(query string) (string, []spelling.Correction) {
+	v := vocabulary.Load()
+	if v == nil {
+		return query, []spelling.Correction{}
+	}
+	corrected, corrections := v.Correct(query)
+	if strings.EqualFold(corrected, query) {
+		return query, []spelling.Correction{}
+	}
+	return corrected, corrections
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const defaultStorePath = "mtguru.db"
+
+// The store is a bbolt file with one top-level bucket per user, each holding
+// a bucket per kind of record keyed by the record id:
+//
+//	users/<user id>/searches/<search id>  -> SavedSearch
+//	users/<user id>/favorites/<card id>   -> Favorite
+var (
+	usersBucket     = []byte("users")
+	searchesBucket  = []byte("searches")
+	favoritesBucket = []byte("favorites")
+)
+
+var errRecordNotFound = errors.New("record not found")
+
+var store *bolt.DB
+
+func storePath() string {
+	if activeConfig.STORE_PATH != "" {
+		return activeConfig.STORE_PATH
+	}
+	return defaultStorePath
+}
+
+func openStore(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// userBucket returns the user's bucket of the given kind. In a read-only
+// transaction it returns nil if the user has no records of that kind yet.
+func userBucket(tx *bolt.Tx, userID string, kind []byte) (*bolt.Bucket, error) {
+	users := tx.Bucket(usersBucket)
+	if !tx.Writable() {
+		user := users.Bucket([]byte(userID))
+		if user == nil {
+			return nil, nil
+		}
+		return user.Bucket(kind), nil
+	}
+	user, err := users.CreateBucketIfNotExists([]byte(userID))
+	if err != nil {
+		return nil, err
+	}
+	return user.CreateBucketIfNotExists(kind)
+}
+
+func putRecord(userID string, kind []byte, id string, record any) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return store.Update(func(tx *bolt.Tx) error {
+		bucket, err := userBucket(tx, userID, kind)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), value)
+	})
+}
+
+// updateRecord reads a record, passes it to update and writes it back in a
+// single transaction, so concurrent updates of the same record cannot lose
+// each other's changes. An error from update aborts without writing.
+func updateRecord[T any](userID string, kind []byte, id string, update func(*T) error) error {
+	return store.Update(func(tx *bolt.Tx) error {
+		bucket, err := userBucket(tx, userID, kind)
+		if err != nil {
+			return err
+		}
+		value := bucket.Get([]byte(id))
+		if value == nil {
+			return errRecordNotFound
+		}
+		var record T
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		if err := update(&record); err != nil {
+			return err
+		}
+		value, err = json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), value)
+	})
+}
+
+func getRecord(userID string, kind []byte, id string, record any) error {
+	return store.View(func(tx *bolt.Tx) error {
+		bucket, err := userBucket(tx, userID, kind)
+		if err != nil {
+			return err
+		}
+		if bucket == nil {
+			return errRecordNotFound
+		}
+		value := bucket.Get([]byte(id))
+		if value == nil {
+			return errRecordNotFound
+		}
+		return json.Unmarshal(value, record)
+	})
+}
+
+func deleteRecord(userID string, kind []byte, id string) error {
+	return store.Update(func(tx *bolt.Tx) error {
+		bucket, err := userBucket(tx, userID, kind)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(id)) == nil {
+			return errRecordNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// listRecords decodes every record of a kind, in key order, calling add for
+// each one.
+func listRecords[T any](userID string, kind []byte, add func(T)) error {
+	return store.View(func(tx *bolt.Tx) error {
+		bucket, err := userBucket(tx, userID, kind)
+		if err != nil || bucket == nil {
+			return err
+		}
+		return bucket.ForEach(func(_, value []byte) error {
+			var record T
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			add(record)
+			return nil
+		})
+	})
+}
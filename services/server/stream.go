@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mtguru/packages/custom_logger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A plain search is retrieved page by page so the client can render the
+// first cards while more arrive. The first page is kept small; later pages
+// double in size to bound the number of round trips to Weaviate.
+const firstStreamChunkSize = 10
+
+// StreamEvent is one line of NDJSON, or the payload of one SSE message.
+type StreamEvent struct {
+	Event string `json:"event" openapi:"enum=query_parsed|searching|retrieved|chunk|hit|done|error"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// streamQueryParsedData echoes the request along with how its query was
+// rewritten before searching.
+type streamQueryParsedData struct {
+	MTGuruSearchRequest
+	QueryRewrite QueryRewrite `json:"query_rewrite"`
+}
+
+type streamSearchingData struct {
+	Mode       string `json:"mode"`
+	Limit      int    `json:"limit"`
+	Candidates int    `json:"candidates" doc:"Hits retrieved before reranking or diversification; limit when neither applies"`
+}
+
+// streamRetrievedData is sent when a reranked, diversified or cut off search
+// has its candidates and starts processing them.
+type streamRetrievedData struct {
+	Candidates int `json:"candidates"`
+}
+
+type streamChunkData struct {
+	Offset int `json:"offset"`
+	Count  int `json:"count"`
+}
+
+type streamDoneData struct {
+	Total     int            `json:"total"`
+	Rerank    *RerankInfo    `json:"rerank,omitempty"`
+	Diversify *DiversifyInfo `json:"diversify,omitempty"`
+	Cutoff    *CutoffInfo    `json:"cutoff,omitempty"`
+}
+
+// streamWriter writes events in either NDJSON or SSE framing, flushing after
+// each one so proxies and browsers see them immediately.
+type streamWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	sse        bool
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request) *streamWriter {
+	format := r.URL.Query().Get("format")
+	sse := format == "sse" || (format == "" && strings.Contains(r.Header.Get("Accept"), "text/event-stream"))
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	return &streamWriter{w: w, controller: http.NewResponseController(w), sse: sse}
+}
+
+func (s *streamWriter) send(event string, data any) error {
+	var err error
+	if s.sse {
+		payload, marshalErr := json.Marshal(data)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	} else {
+		line, marshalErr := json.Marshal(StreamEvent{Event: event, Data: data})
+		if marshalErr != nil {
+			return marshalErr
+		}
+		_, err = s.w.Write(append(line, '\n'))
+	}
+	if err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// sendChunk sends cards as hit events followed by a chunk event.
+func (s *streamWriter) sendChunk(offset int, cards []map[string]any) error {
+	for _, card := range cards {
+		if err := s.send("hit", card); err != nil {
+			return err
+		}
+	}
+	return s.send("chunk", streamChunkData{Offset: offset, Count: len(cards)})
+}
+
+func searchStreamHandler(w http.ResponseWriter, r *http.Request) {
+
+	var requestBody MTGuruSearchRequest
+	if errs := decodeJSONBody(r, &requestBody); errs != nil {
+		slog.DebugContext(r.Context(), "Error decoding request body", "errors", errs)
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Received streaming search request:", "query", requestBody.Query, "filters", requestBody.Filters)
+
+	stream := newStreamWriter(w, r)
+	limit := searchLimit(requestBody.Limit)
+	mode := requestBody.Mode
+	if mode == "" {
+		mode = searchModeSemantic
+	}
+
+	start := time.Now()
+	fail := func(ctx context.Context, err error) {
+		httpErr := classifySearchError(r.Context(), ctx, err)
+		slog.WarnContext(r.Context(), "Streaming search failed", "status", httpErr.status, "code", httpErr.code, "error", err.Error())
+		recordSearch(r.Context(), searchSourceStream, requestBody, start, nil, httpErr.code)
+		if httpErr.status != statusClientClosedRequest {
+			stream.send("error", APIError{
+				Code:      httpErr.code,
+				Message:   httpErr.message,
+				RequestID: custom_logger.RequestIDFromContext(r.Context()),
+			})
+		}
+	}
+
+	search, rewrite := rewriteQuery(requestBody)
+	if err := stream.send("query_parsed", streamQueryParsedData{MTGuruSearchRequest: requestBody, QueryRewrite: rewrite}); err != nil {
+		return
+	}
+	plan, err := planSearch(search, rewrite, limit)
+	if err != nil {
+		fail(r.Context(), err)
+		return
+	}
+	if err := stream.send("searching", streamSearchingData{Mode: mode, Limit: limit, Candidates: plan.retrieve}); err != nil {
+		return
+	}
+
+	if plan.processed() {
+		streamProcessedSearch(r, stream, requestBody, plan, start, fail)
+		return
+	}
+
+	// Nothing reorders a plain search, so its pages can go out as they arrive
+	var sent []map[string]any
+	chunkSize := firstStreamChunkSize
+	for len(sent) < limit {
+		if r.Context().Err() != nil {
+			slog.InfoContext(r.Context(), "Client closed stream", "status", statusClientClosedRequest, "sent", len(sent))
+			return
+		}
+
+		size := min(chunkSize, limit-len(sent))
+		ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+		response, err := searchDatabase(ctx, search, size, len(sent))
+		if err != nil {
+			fail(ctx, err)
+			cancel()
+			return
+		}
+		cancel()
+
+		page := resultCards(response)
+		if err := stream.sendChunk(len(sent), page); err != nil {
+			return
+		}
+		sent = append(sent, page...)
+		if len(page) < size {
+			// Fewer matches than asked for, nothing further to page through
+			break
+		}
+		chunkSize *= 2
+	}
+
+	recordSearch(r.Context(), searchSourceStream, requestBody, start, sent, "")
+	stream.send("done", streamDoneData{Total: len(sent)})
+}
+
+// streamProcessedSearch retrieves the candidate pool in one query, reports
+// it, and sends the hits once the cutoff, reranker and diversification have
+// run, since those can reorder any of them.
+func streamProcessedSearch(r *http.Request, stream *streamWriter, request MTGuruSearchRequest, plan searchPlan, start time.Time, fail func(context.Context, error)) {
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout(activeConfig))
+	defer cancel()
+
+	response, err := searchDatabase(ctx, plan.search, plan.retrieve, 0)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	if err := stream.send("retrieved", streamRetrievedData{Candidates: len(resultCards(response))}); err != nil {
+		return
+	}
+
+	results := plan.process(ctx, response)
+	cards := results.cards()
+	if err := stream.sendChunk(0, cards); err != nil {
+		return
+	}
+
+	recordSearch(r.Context(), searchSourceStream, request, start, cards, "")
+	stream.send("done", streamDoneData{
+		Total:     len(cards),
+		Rerank:    results.Rerank,
+		Diversify: results.Diversify,
+		Cutoff:    results.Cutoff,
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxRequestBodyBytes = 1 << 20
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// decodeJSONBody reads the request body, validates it against the OpenAPI
+// schema generated for dst's type and only then unmarshals it into dst.
+func decodeJSONBody(r *http.Request, dst any) []fieldError {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBodyBytes))
+	if err != nil {
+		return []fieldError{{Field: "", Message: fmt.Sprintf("could not read request body: %v", err)}}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return []fieldError{{Field: "", Message: fmt.Sprintf("malformed JSON: %v", err)}}
+	}
+	if decoder.More() {
+		return []fieldError{{Field: "", Message: "unexpected data after JSON value"}}
+	}
+
+	spec := getAPISpec()
+	schema := spec.gen.schemaFor(reflect.TypeOf(dst).Elem())
+	if errs := spec.validate(schema, raw, ""); len(errs) > 0 {
+		return errs
+	}
+
+	if err := json.Unmarshal(body, dst); err != nil {
+		return []fieldError{{Field: "", Message: err.Error()}}
+	}
+	return nil
+}
+
+func joinFieldPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}
+
+func (s *apiSpec) resolve(schema *openAPISchema) *openAPISchema {
+	for schema.Ref != "" {
+		schema = s.gen.schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// validate checks a decoded JSON value against the subset of JSON Schema the
+// generator emits and returns one error per offending field.
+func (s *apiSpec) validate(schema *openAPISchema, value any, path string) []fieldError {
+	schema = s.resolve(schema)
+	for _, sub := range schema.AllOf {
+		if errs := s.validate(sub, value, path); len(errs) > 0 {
+			return errs
+		}
+	}
+
+	if value == nil {
+		if schema.Type == "" || schema.Nullable {
+			return nil
+		}
+		return []fieldError{{Field: path, Message: fmt.Sprintf("must be %s, got null", schema.Type)}}
+	}
+
+	var errs []fieldError
+	fail := func(format string, args ...any) []fieldError {
+		return append(errs, fieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object, got %s", jsonTypeName(value))
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fieldError{Field: joinFieldPath(path, name), Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := schema.Properties[key]; ok {
+				errs = append(errs, s.validate(prop, obj[key], joinFieldPath(path, key))...)
+				continue
+			}
+			switch extra := schema.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					errs = append(errs, fieldError{Field: joinFieldPath(path, key), Message: "is not a recognised field"})
+				}
+			case *openAPISchema:
+				errs = append(errs, s.validate(extra, obj[key], joinFieldPath(path, key))...)
+			}
+		}
+		return errs
+
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fail("must be an array, got %s", jsonTypeName(value))
+		}
+		if schema.MaxItems != nil && len(arr) > *schema.MaxItems {
+			return fail("must contain at most %d items", *schema.MaxItems)
+		}
+		for i, item := range arr {
+			errs = append(errs, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string, got %s", jsonTypeName(value))
+		}
+		length := utf8.RuneCountInString(str)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return fail("must be at least %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return fail("must be at most %d characters", *schema.MaxLength)
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, any(str)) {
+			return fail("must be one of %s", formatEnum(schema.Enum))
+		}
+		return nil
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fail("must be %s, got %s", article(schema.Type), jsonTypeName(value))
+		}
+		if schema.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				return fail("must be an integer")
+			}
+		}
+		f, _ := num.Float64()
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return fail("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return fail("must be at most %v", *schema.Maximum)
+		}
+		return nil
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean, got %s", jsonTypeName(value))
+		}
+		return nil
+	}
+	return nil
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return "unknown"
+}
+
+func article(typeName string) string {
+	if typeName == "integer" {
+		return "an integer"
+	}
+	return "a " + typeName
+}
+
+func formatEnum(values []any) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
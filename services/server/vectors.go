@@ -0,0 +1,138 @@
+package main
+
+import "math"
+
+// Weaviate stores text2vec-openai embeddings and compares them by cosine
+// distance, so everything here works on cosine similarity too.
+
+// parseVector converts the _additional.vector value of a GraphQL result.
+func parseVector(raw any) []float32 {
+	values, ok := raw.([]any)
+	if !ok {
+		return nil
+	}
+	vector := make([]float32, 0, len(values))
+	for _, value := range values {
+		f, ok := value.(float64)
+		if !ok {
+			return nil
+		}
+		vector = append(vector, float32(f))
+	}
+	return vector
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float32, len(vector))
+	if norm == 0 {
+		return out
+	}
+	for i, v := range vector {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out
+}
+
+// weightedCentroid averages the normalised vectors, weighting each by the
+// matching entry in weights. The result is normalised again.
+func weightedCentroid(vectors [][]float32, weights []float64) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+	sum := make([]float64, len(vectors[0]))
+	for i, vector := range vectors {
+		unit := normalize(vector)
+		for j := range sum {
+			sum[j] += float64(unit[j]) * weights[i]
+		}
+	}
+	centroid := make([]float32, len(sum))
+	for j, v := range sum {
+		centroid[j] = float32(v)
+	}
+	return normalize(centroid)
+}
+
+// kMeans groups vectors into k clusters by cosine similarity and returns the
+// cluster index of every vector. Initial centres are picked by farthest-point
+// traversal from the first vector, which keeps the result deterministic.
+func kMeans(vectors [][]float32, weights []float64, k int, iterations int) []int {
+	assignments := make([]int, len(vectors))
+	if k <= 1 || len(vectors) <= k {
+		for i := range assignments {
+			assignments[i] = min(i, max(k-1, 0))
+		}
+		return assignments
+	}
+
+	centres := [][]float32{normalize(vectors[0])}
+	for len(centres) < k {
+		farthest, farthestSimilarity := 0, math.Inf(1)
+		for i, vector := range vectors {
+			best := math.Inf(-1)
+			for _, centre := range centres {
+				best = math.Max(best, cosineSimilarity(vector, centre))
+			}
+			if best < farthestSimilarity {
+				farthest, farthestSimilarity = i, best
+			}
+		}
+		centres = append(centres, normalize(vectors[farthest]))
+	}
+
+	for iteration := 0; iteration < iterations; iteration++ {
+		changed := false
+		for i, vector := range vectors {
+			best, bestSimilarity := 0, math.Inf(-1)
+			for c, centre := range centres {
+				if similarity := cosineSimilarity(vector, centre); similarity > bestSimilarity {
+					best, bestSimilarity = c, similarity
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+
+		for c := range centres {
+			var members [][]float32
+			var memberWeights []float64
+			for i, assignment := range assignments {
+				if assignment == c {
+					members = append(members, vectors[i])
+					memberWeights = append(memberWeights, weights[i])
+				}
+			}
+			if len(members) > 0 {
+				centres[c] = weightedCentroid(members, memberWeights)
+			}
+		}
+
+		if !changed && iteration > 0 {
+			break
+		}
+	}
+	return assignments
+}